@@ -2,12 +2,22 @@ package main
 
 import (
 	"bufio"
+	"os"
 )
 
 func readAddInfo(st *sttsT, vars *varsT) {
 	var addInfo []string
 
-	for _, fd := range vars.addInfoFd {
+	for i, fd := range vars.addInfoFd {
+		if fd == nil {
+			var err error
+			fd, err = os.Open(vars.addInfoFiles[i])
+			if err != nil {
+				continue
+			}
+			vars.addInfoFd[i] = fd
+		}
+
 		fileInfo, err := fd.Stat()
 		if err != nil || fileInfo.Size() == 0 {
 			continue
@@ -0,0 +1,250 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	str "strings"
+)
+
+type alertRuleT struct {
+	name   string
+	metric string
+	op     string
+	val    float64
+
+	// how long the condition has to hold before firing and how far the
+	// value has to move back past val to resolve
+	dur  time.Duration
+	hyst float64
+}
+
+type alertStateT struct {
+	rule   alertRuleT
+	key    string
+	since  time.Time
+	firing bool
+	val    float64
+}
+
+var alertRe = regexp.MustCompile(
+	`^([^:]+):([^<>=!]+)(<=|>=|==|!=|<|>)([^:]+)(?::([^:]*))?(?::([^:]*))?$`)
+
+// parseAlert reads an alert value: name:metric<op>value[:duration[:hyst]],
+// e.g. bat_low:battery.level<10:30s:2
+func parseAlert(val string) (alertRuleT, error) {
+	var rule alertRuleT
+	errBad := errors.New("incorrect alert rule")
+
+	match := alertRe.FindStringSubmatch(val)
+	if match == nil {
+		return rule, errBad
+	}
+
+	rule = alertRuleT{name: match[1], metric: match[2], op: match[3]}
+
+	var err error
+	rule.val, err = strconv.ParseFloat(match[4], 64)
+	if err != nil {
+		return rule, errBad
+	}
+
+	if match[5] != "" {
+		rule.dur, err = time.ParseDuration(match[5])
+		if err != nil {
+			return rule, errBad
+		}
+	}
+
+	if match[6] != "" {
+		rule.hyst, err = strconv.ParseFloat(match[6], 64)
+		if err != nil || rule.hyst < 0 {
+			return rule, errBad
+		}
+	}
+
+	_, err = path.Match(rule.metric, "")
+	if err != nil {
+		return rule, errBad
+	}
+
+	return rule, nil
+}
+
+// checkAlerts evaluates every rule against every metric it matches and runs
+// the alert actions when one starts or stops firing; an alert whose metric
+// is gone, e.g. of an unmounted disk, resolves with its last value
+func checkAlerts(st *sttsT, vars *varsT) {
+	if len(vars.alerts) == 0 {
+		return
+	}
+
+	// the alert file is created, or emptied of an earlier run, when
+	// alerting starts
+	changed := false
+	if vars.alertStates == nil {
+		vars.alertStates = make(map[string]*alertStateT)
+		changed = vars.alertFile != ""
+	}
+
+	now := time.Now()
+	seen := make(map[string]bool)
+
+	for key, val := range getKvVals(st, vars) {
+		for _, rule := range vars.alerts {
+			if match, _ := path.Match(rule.metric, key); !match {
+				continue
+			}
+
+			id := rule.name + " " + key
+			seen[id] = true
+			state, ok := vars.alertStates[id]
+			if !ok {
+				state = &alertStateT{rule: rule, key: key}
+				vars.alertStates[id] = state
+			}
+
+			if state.update(val, now) {
+				changed = true
+				runAlertActions(state, vars)
+			}
+		}
+	}
+
+	for id, state := range vars.alertStates {
+		if seen[id] {
+			continue
+		}
+
+		delete(vars.alertStates, id)
+		if state.firing {
+			state.firing = false
+			changed = true
+			runAlertActions(state, vars)
+		}
+	}
+
+	if changed {
+		writeAlertFile(vars)
+	}
+}
+
+// update sets the value of an alert and returns true when it starts or
+// stops firing
+func (state *alertStateT) update(val float64, now time.Time) bool {
+	rule := state.rule
+	state.val = val
+
+	if !state.firing {
+		if !rule.holds(val, 0) {
+			state.since = time.Time{}
+			return false
+		}
+		if state.since.IsZero() {
+			state.since = now
+		}
+		if now.Sub(state.since) < rule.dur {
+			return false
+		}
+		state.firing = true
+		return true
+	}
+
+	if rule.holds(val, rule.hyst) {
+		return false
+	}
+	state.firing = false
+	state.since = time.Time{}
+	return true
+}
+
+// holds returns whether the condition is met, with the threshold moved back
+// by hyst so a firing alert doesn't flap around it
+func (rule alertRuleT) holds(val, hyst float64) bool {
+	switch rule.op {
+	case "<":
+		return val < rule.val+hyst
+	case "<=":
+		return val <= rule.val+hyst
+	case ">":
+		return val > rule.val-hyst
+	case ">=":
+		return val >= rule.val-hyst
+	case "==":
+		return val == rule.val
+	case "!=":
+		return val != rule.val
+	}
+	return false
+}
+
+func runAlertActions(state *alertStateT, vars *varsT) {
+	rule, key := state.rule, state.key
+
+	status := "resolved"
+	if state.firing {
+		status = "firing"
+	}
+	valStr := strconv.FormatFloat(state.val, 'f', -1, 64)
+
+	if vars.alertCmd != "" {
+		cmd := exec.Command("sh", "-c", vars.alertCmd)
+		cmd.Env = append(os.Environ(),
+			"ALERT_NAME="+rule.name,
+			"ALERT_METRIC="+key,
+			"ALERT_VALUE="+valStr,
+			"ALERT_STATUS="+status)
+		startAlertCmd(cmd)
+	}
+
+	if vars.alertNotify != "" {
+		summary := "stts: " + rule.name + " " + status
+		body := fmt.Sprintf("%s is %s (%s %g)", key, valStr, rule.op,
+			rule.val)
+		startAlertCmd(exec.Command(vars.alertNotify, summary, body))
+	}
+}
+
+func startAlertCmd(cmd *exec.Cmd) {
+	err := cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "alert command failed: %s\n", err)
+		return
+	}
+
+	go cmd.Wait()
+}
+
+// writeAlertFile writes the firing alerts one per line, so the file can be
+// used as add_info; it is empty when nothing fires
+func writeAlertFile(vars *varsT) {
+	if vars.alertFile == "" {
+		return
+	}
+
+	var firing []string
+	for id, state := range vars.alertStates {
+		if state.firing {
+			firing = append(firing, id+"="+
+				strconv.FormatFloat(state.val, 'f', -1, 64))
+		}
+	}
+	sort.Strings(firing)
+
+	out := str.Join(firing, "\n")
+	if out != "" {
+		out += "\n"
+	}
+
+	err := os.WriteFile(vars.alertFile, []byte(out), 0644)
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "writing alert file failed: %s\n", err)
+	}
+}
@@ -0,0 +1,163 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseAlert(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    alertRuleT
+		wantErr bool
+	}{
+		{in: "bat_low:battery.level<10",
+			want: alertRuleT{name: "bat_low", metric: "battery.level",
+				op: "<", val: 10}},
+		{in: "bat_low:battery.level<10:1m:2",
+			want: alertRuleT{name: "bat_low", metric: "battery.level",
+				op: "<", val: 10, dur: time.Minute, hyst: 2}},
+		{in: "hot:temps.*.max>=80:30s:5",
+			want: alertRuleT{name: "hot", metric: "temps.*.max",
+				op: ">=", val: 80, dur: 30 * time.Second, hyst: 5}},
+		{in: "ac:ac_online==0",
+			want: alertRuleT{name: "ac", metric: "ac_online", op: "==",
+				val: 0}},
+		{in: "vpn:vpn.up!=1::",
+			want: alertRuleT{name: "vpn", metric: "vpn.up", op: "!=",
+				val: 1}},
+		{in: "load:loads.0>-1.5::0.5",
+			want: alertRuleT{name: "load", metric: "loads.0", op: ">",
+				val: -1.5, hyst: 0.5}},
+		{in: "battery.level<10", wantErr: true},
+		{in: "low:battery.level", wantErr: true},
+		{in: "low:battery.level<ten", wantErr: true},
+		{in: "low:battery.level<10:soon", wantErr: true},
+		{in: "low:battery.level<10:1m:-2", wantErr: true},
+		{in: "low:battery.level<10:1m:2:extra", wantErr: true},
+		{in: "low:battery[.level<10", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseAlert(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseAlert(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseAlert(%q) = %+v, want %+v", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestAlertHolds(t *testing.T) {
+	tests := []struct {
+		op   string
+		val  float64
+		hyst float64
+		want bool
+	}{
+		{"<", 9, 0, true},
+		{"<", 10, 0, false},
+		{"<", 11, 2, true},
+		{"<=", 10, 0, true},
+		{">", 11, 0, true},
+		{">", 9, 2, true},
+		{">", 8, 2, false},
+		{">=", 10, 0, true},
+		{"==", 10, 5, true},
+		{"==", 11, 5, false},
+		{"!=", 11, 0, true},
+		{"!=", 10, 0, false},
+	}
+
+	for _, tt := range tests {
+		rule := alertRuleT{op: tt.op, val: 10}
+		if got := rule.holds(tt.val, tt.hyst); got != tt.want {
+			t.Errorf("%g %s 10 with hyst %g = %t, want %t", tt.val, tt.op,
+				tt.hyst, got, tt.want)
+		}
+	}
+}
+
+func TestAlertUpdate(t *testing.T) {
+	type step struct {
+		at      time.Duration
+		val     float64
+		changed bool
+		firing  bool
+	}
+
+	tests := []struct {
+		name  string
+		rule  alertRuleT
+		steps []step
+	}{
+		{"fires at once without duration",
+			alertRuleT{op: "<", val: 10},
+			[]step{
+				{0, 20, false, false},
+				{time.Second, 5, true, true},
+				{2 * time.Second, 5, false, true},
+				{3 * time.Second, 15, true, false},
+			}},
+		{"holds for the duration before firing",
+			alertRuleT{op: ">", val: 80, dur: 30 * time.Second},
+			[]step{
+				{0, 85, false, false},
+				{20 * time.Second, 90, false, false},
+				{30 * time.Second, 90, true, true},
+			}},
+		{"dipping below restarts the duration",
+			alertRuleT{op: ">", val: 80, dur: 30 * time.Second},
+			[]step{
+				{0, 85, false, false},
+				{20 * time.Second, 70, false, false},
+				{40 * time.Second, 85, false, false},
+				{60 * time.Second, 85, false, false},
+				{70 * time.Second, 85, true, true},
+			}},
+		{"hysteresis keeps it firing",
+			alertRuleT{op: ">", val: 80, hyst: 5},
+			[]step{
+				{0, 81, true, true},
+				{time.Second, 79, false, true},
+				{2 * time.Second, 76, false, true},
+				{3 * time.Second, 75, true, false},
+				{4 * time.Second, 79, false, false},
+			}},
+	}
+
+	start := time.Unix(1700000000, 0)
+	for _, tt := range tests {
+		state := &alertStateT{rule: tt.rule}
+		for i, s := range tt.steps {
+			changed := state.update(s.val, start.Add(s.at))
+			if changed != s.changed || state.firing != s.firing {
+				t.Errorf("%s: step %d changed %t firing %t, want %t %t",
+					tt.name, i, changed, state.firing, s.changed, s.firing)
+			}
+			if state.val != s.val {
+				t.Errorf("%s: step %d val %g, want %g", tt.name, i,
+					state.val, s.val)
+			}
+		}
+	}
+}
+
+func TestCheckAlertsGoneMetric(t *testing.T) {
+	rule := alertRuleT{name: "full", metric: "disks.*.free", op: "<",
+		val: 1}
+	vars := &varsT{alerts: []alertRuleT{rule}}
+
+	// a firing alert of a metric no longer sampled resolves and is dropped
+	vars.alertStates = map[string]*alertStateT{
+		"full disks._mnt_usb.free": {rule: rule, key: "disks._mnt_usb.free",
+			firing: true},
+	}
+	checkAlerts(&sttsT{}, vars)
+
+	if len(vars.alertStates) != 0 {
+		t.Errorf("alert states = %v, want none", vars.alertStates)
+	}
+}
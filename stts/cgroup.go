@@ -0,0 +1,311 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/fs"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type cgroupT struct {
+	// path relative to the cgroup2 mount, "/" for the root
+	path string
+
+	// systemd unit the cgroup is part of and the owner of a user slice
+	unit string
+	user string
+
+	mem     int64
+	pids    int64
+	cpuUsec uint64
+	ioRead  uint64
+	ioWrite uint64
+
+	// cpu usage in percent of one core and io bytes per second between two
+	// samples
+	cpu    float64
+	ioRate float64
+
+	procs []processT
+}
+
+var userSliceRe = regexp.MustCompile(`^user-([0-9]+)\.slice$`)
+
+var unitSuffixes = []string{".service", ".scope", ".slice", ".socket",
+	".mount", ".swap"}
+
+// printCgroups lists the cgroups with processes and their resource usage,
+// each followed by the processes directly in it
+func printCgroups(st *sttsT, vars *varsT) {
+	root := findCgroup2Root()
+	if root == "" {
+		errExit(fmt.Errorf("no cgroup2 hierarchy mounted"))
+	}
+
+	prev := walkCgroups(root)
+	start := time.Now()
+	time.Sleep(topInterval)
+
+	getAllInfo(st, vars)
+	getProcInfo(st, vars)
+	cgroups := walkCgroups(root)
+	setCgroupRates(prev, cgroups, time.Since(start))
+
+	procs := make(map[string][]processT)
+	for _, p := range filterProcs(st.ps, vars) {
+		path := getProcCgroup(p.pid)
+		procs[path] = append(procs[path], p)
+	}
+
+	var shown []cgroupT
+	for _, cg := range cgroups {
+		cg.procs = procs[cg.path]
+		sort.Slice(cg.procs, func(i, j int) bool {
+			return cg.procs[i].stat.pid < cg.procs[j].stat.pid
+		})
+		if !cgroupShown(cg, procs, vars) {
+			continue
+		}
+		shown = append(shown, cg)
+	}
+
+	sortCgroups(shown, vars.top.sort)
+	if vars.top.limit > 0 && len(shown) > vars.top.limit {
+		shown = shown[:vars.top.limit]
+	}
+
+	// the processes are listed by pid in their cgroup
+	if vars.format != "" {
+		st.ps = nil
+		st.cgroups = shown
+		printExport(st, vars)
+		return
+	}
+
+	printOneLineOnce(st, vars)
+	sep()
+	printCgroupTable(shown)
+}
+
+// findCgroup2Root returns where the unified hierarchy is mounted, which is
+// /sys/fs/cgroup/unified on hybrid systems
+func findCgroup2Root() string {
+	fd, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return ""
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) >= 3 && fields[2] == "cgroup2" {
+			return unescapeMount(fields[1])
+		}
+	}
+
+	return ""
+}
+
+func walkCgroups(root string) []cgroupT {
+	var cgroups []cgroupT
+
+	fp.WalkDir(root, func(dir string, d fs.DirEntry, err error) error {
+		if err != nil || !d.IsDir() {
+			return nil
+		}
+
+		path := "/" + str.TrimPrefix(str.TrimPrefix(dir, root), "/")
+		cgroups = append(cgroups, readCgroup(dir, path))
+		return nil
+	})
+
+	return cgroups
+}
+
+func readCgroup(dir, path string) cgroupT {
+	cg := cgroupT{path: path}
+	cg.unit, cg.user = cgroupUnit(path)
+
+	cg.mem = int64(readSysFloat(fp.Join(dir, "memory.current")))
+
+	for _, line := range str.Split(readSysString(fp.Join(dir, "cpu.stat")),
+		"\n") {
+		key, val, _ := str.Cut(line, " ")
+		if key == "usage_usec" {
+			cg.cpuUsec, _ = strconv.ParseUint(val, 10, 64)
+		}
+	}
+
+	// one line per device, e.g. 8:0 rbytes=1 wbytes=2 rios=3 wios=4
+	for _, line := range str.Split(readSysString(fp.Join(dir, "io.stat")),
+		"\n") {
+		for _, field := range str.Fields(line) {
+			key, val, _ := str.Cut(field, "=")
+			n, _ := strconv.ParseUint(val, 10, 64)
+			switch key {
+			case "rbytes":
+				cg.ioRead += n
+			case "wbytes":
+				cg.ioWrite += n
+			}
+		}
+	}
+
+	// the root and cgroups without the pids controller have no
+	// pids.current, count their own processes instead
+	pidsFile := fp.Join(dir, "pids.current")
+	if fileExists(pidsFile) {
+		cg.pids = int64(readSysFloat(pidsFile))
+	} else {
+		procs := readSysString(fp.Join(dir, "cgroup.procs"))
+		cg.pids = int64(len(str.Fields(procs)))
+	}
+
+	return cg
+}
+
+// setCgroupRates computes cpu usage and io rate of every cgroup in cur from
+// the usage and io bytes it had in prev, the earlier sample
+func setCgroupRates(prev, cur []cgroupT, elapsed time.Duration) {
+	prevCgroups := make(map[string]cgroupT)
+	for _, cg := range prev {
+		prevCgroups[cg.path] = cg
+	}
+
+	for i := range cur {
+		cg := &cur[i]
+		prevCg, ok := prevCgroups[cg.path]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+
+		if cg.cpuUsec >= prevCg.cpuUsec {
+			cg.cpu = float64(cg.cpuUsec-prevCg.cpuUsec) /
+				float64(elapsed.Microseconds()) * 100
+		}
+
+		cg.ioRate = counterRate(prevCg.ioRead+prevCg.ioWrite,
+			cg.ioRead+cg.ioWrite, elapsed.Seconds())
+	}
+}
+
+// getProcCgroup returns the cgroup2 path of a process from the 0:: line
+func getProcCgroup(pid string) string {
+	bin, err := os.ReadFile(fp.Join("/proc", pid, "cgroup"))
+	if err != nil {
+		return ""
+	}
+
+	for _, line := range str.Split(string(bin), "\n") {
+		if str.HasPrefix(line, "0::") {
+			return str.TrimPrefix(line, "0::")
+		}
+	}
+
+	return ""
+}
+
+// cgroupUnit returns the innermost systemd unit of a cgroup path and the
+// user name when it is in a user slice
+func cgroupUnit(path string) (string, string) {
+	var unit, user string
+
+	for _, name := range str.Split(path, "/") {
+		if match := userSliceRe.FindStringSubmatch(name); match != nil {
+			uid, _ := strconv.Atoi(match[1])
+			user = getUserName(uid)
+		}
+
+		for _, suffix := range unitSuffixes {
+			if str.HasSuffix(name, suffix) {
+				unit = name
+			}
+		}
+	}
+
+	return unit, user
+}
+
+// cgroupShown hides empty cgroups and, with -user or -match, those without
+// a matching process anywhere below them
+func cgroupShown(cg cgroupT, procs map[string][]processT, vars *varsT) bool {
+	if cg.pids == 0 && len(cg.procs) == 0 {
+		return false
+	}
+
+	if vars.top.user == "" && vars.top.match == nil {
+		return true
+	}
+
+	if vars.top.match != nil && vars.top.match.MatchString(cg.path) {
+		return true
+	}
+
+	for path, ps := range procs {
+		if len(ps) > 0 && (path == cg.path ||
+			str.HasPrefix(path, str.TrimSuffix(cg.path, "/")+"/")) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func sortCgroups(cgroups []cgroupT, by string) {
+	var less func(a, b cgroupT) bool
+
+	switch by {
+	case "mem":
+		less = func(a, b cgroupT) bool {
+			return a.mem > b.mem
+		}
+	case "io":
+		less = func(a, b cgroupT) bool {
+			return a.ioRate > b.ioRate
+		}
+	default:
+		less = func(a, b cgroupT) bool {
+			return a.cpu > b.cpu
+		}
+	}
+
+	sort.SliceStable(cgroups, func(i, j int) bool {
+		return less(cgroups[i], cgroups[j])
+	})
+}
+
+func printCgroupTable(cgroups []cgroupT) {
+	mb := float64(1024 * 1024)
+
+	fmt.Printf("%6s %8s %8s %5s  %s\n", "CPU%", "MEM MB", "IO/S", "PIDS",
+		"CGROUP")
+
+	for _, cg := range cgroups {
+		name := cg.path
+		if cg.unit != "" && cg.unit != fp.Base(cg.path) {
+			name += " [" + cg.unit + "]"
+		}
+		if cg.user != "" {
+			name += " (" + cg.user + ")"
+		}
+
+		fmt.Printf("%6.1f %8.1f %8s %5d  %s\n", cg.cpu,
+			float64(cg.mem)/mb, fmtBytes(cg.ioRate), cg.pids, name)
+
+		for _, p := range cg.procs {
+			cmd := str.ReplaceAll(p.args, "\n", " ")
+			if cmd == "" {
+				cmd = p.stat.comm
+			}
+			fmt.Printf("%32s %7s  %s\n", "", p.pid, cmd)
+		}
+	}
+}
@@ -0,0 +1,166 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"sync"
+	"time"
+)
+
+// oldest schema version of the agents; the fields the table reads haven't
+// changed since 2, raise it when one of them is renamed or removed
+const collectMinVersion = 2
+
+type collectHostT struct {
+	addr string
+
+	// the last snapshot received, when it came and the error of the last
+	// poll, nil when it succeeded
+	snap *exportT
+	last time.Time
+	err  error
+}
+
+// runCollector polls the snapshots of the collect_host agents, which run
+// stts -serve, and prints them as one table; a host whose last poll failed
+// is shown as stale with its previous values until collect_stale passes
+func runCollector(once bool, vars *varsT) {
+	if len(vars.collectHosts) == 0 {
+		errExit(fmt.Errorf("no collect_host configured"))
+	}
+
+	var hosts []*collectHostT
+	for _, addr := range vars.collectHosts {
+		hosts = append(hosts, &collectHostT{addr: addr})
+	}
+
+	client := &http.Client{Timeout: vars.collectInterval}
+
+	tick := time.NewTicker(vars.collectInterval)
+	defer tick.Stop()
+
+	for {
+		pollHosts(client, hosts)
+		printCollectTable(hosts, vars)
+		if once {
+			return
+		}
+
+		<-tick.C
+		fmt.Println()
+	}
+}
+
+func pollHosts(client *http.Client, hosts []*collectHostT) {
+	var wg sync.WaitGroup
+
+	for _, host := range hosts {
+		wg.Add(1)
+		go func(host *collectHostT) {
+			defer wg.Done()
+
+			snap, err := fetchSnapshot(client, host.addr)
+			host.err = err
+			if err == nil {
+				host.snap = snap
+				host.last = time.Now()
+			}
+		}(host)
+	}
+
+	wg.Wait()
+}
+
+func fetchSnapshot(client *http.Client, addr string) (*exportT, error) {
+	resp, err := client.Get("http://" + addr + "/snapshot")
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("%s", resp.Status)
+	}
+
+	var snap exportT
+	err = json.NewDecoder(resp.Body).Decode(&snap)
+	if err != nil {
+		return nil, err
+	}
+
+	// newer agents are read too, fields this version doesn't know are
+	// ignored by the decoder
+	if snap.Version < collectMinVersion {
+		return nil, fmt.Errorf("schema version %d, expected at least %d",
+			snap.Version, collectMinVersion)
+	}
+
+	return &snap, nil
+}
+
+func printCollectTable(hosts []*collectHostT, vars *varsT) {
+	fmt.Printf("%-24s %-11s %7s %6s %5s %8s %4s  %s\n", "HOST", "STATUS",
+		"UP", "LOAD", "MEM%", "DF GB", "BAT", "TEMP")
+
+	var errs []string
+	for _, host := range hosts {
+		status := "ok"
+		snap := host.snap
+		if host.err != nil {
+			errs = append(errs, host.addr+": "+host.err.Error())
+
+			age := time.Since(host.last)
+			if snap != nil && age < vars.collectStale {
+				status = "stale " + fmtAge(age)
+			} else {
+				status = "unreachable"
+				snap = nil
+			}
+		}
+
+		if snap == nil {
+			fmt.Printf("%-24.24s %-11s %7s %6s %5s %8s %4s  %s\n",
+				host.addr, status, "-", "-", "-", "-", "-", "-")
+			continue
+		}
+
+		var memPerc float64
+		if snap.Mem.Total > 0 {
+			memPerc = float64(snap.Mem.Used) / float64(snap.Mem.Total) *
+				100
+		}
+
+		bat := "-"
+		if snap.Bat != nil {
+			bat = fmt.Sprintf("%d%%", snap.Bat.Level)
+		}
+
+		// exported temperatures are in °C regardless of temp_unit
+		temp := "-"
+		if len(snap.Temps) > 0 {
+			temp = fmtTemp(tempMax(snap), vars)
+		}
+
+		fmt.Printf("%-24.24s %-11s %7s %6.2f %4.0f%% %8.1f %4s  %s\n",
+			host.addr, status,
+			fmtAge(time.Duration(snap.Uptime)*time.Second),
+			snap.Loads[0], memPerc, snap.RootDiskMB/1024, bat, temp)
+	}
+
+	for _, err := range errs {
+		fmt.Fprintf(os.Stderr, "%s\n", err)
+	}
+}
+
+// tempMax returns the highest temperature of any group in millidegrees
+func tempMax(snap *exportT) int {
+	var max float64
+	for i, t := range snap.Temps {
+		if i == 0 || t.Max > max {
+			max = t.Max
+		}
+	}
+	return int(max * 1000)
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	str "strings"
+)
+
+func newAgent(t *testing.T, body string) *httptest.Server {
+	srv := httptest.NewServer(http.HandlerFunc(
+		func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path != "/snapshot" {
+				http.NotFound(w, r)
+				return
+			}
+			fmt.Fprint(w, body)
+		}))
+	t.Cleanup(srv.Close)
+
+	return srv
+}
+
+func snapshotJson(t *testing.T, version int) string {
+	snap := exportT{
+		Version:    version,
+		Uptime:     3700,
+		Loads:      [3]float64{0.42, 0.3, 0.2},
+		Mem:        exportMemT{Total: 1000, Used: 250},
+		RootDiskMB: 2048,
+		Temps:      []exportTempT{{Group: "cpu", Max: 55}},
+	}
+
+	out, err := json.Marshal(snap)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+// captureStdout returns what fn prints to stdout
+func captureStdout(t *testing.T, fn func()) string {
+	rd, wr, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stdout := os.Stdout
+	os.Stdout = wr
+	defer func() { os.Stdout = stdout }()
+
+	fn()
+	wr.Close()
+
+	out, err := io.ReadAll(rd)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(out)
+}
+
+func TestFetchSnapshot(t *testing.T) {
+	newer := str.Replace(snapshotJson(t, schemaVersion+1), "{",
+		`{"new_field":[1,2],`, 1)
+
+	tests := []struct {
+		name    string
+		body    string
+		wantErr bool
+	}{
+		{"current", snapshotJson(t, schemaVersion), false},
+		{"oldest", snapshotJson(t, collectMinVersion), false},
+		{"newer with unknown fields", newer, false},
+		{"too old", snapshotJson(t, collectMinVersion-1), true},
+		{"not json", "<html></html>", true},
+	}
+
+	client := &http.Client{Timeout: time.Second}
+	for _, tt := range tests {
+		srv := newAgent(t, tt.body)
+		addr := srv.Listener.Addr().String()
+
+		snap, err := fetchSnapshot(client, addr)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("%s: fetchSnapshot() error = %v", tt.name, err)
+			continue
+		}
+		if !tt.wantErr && snap.Uptime != 3700 {
+			t.Errorf("%s: uptime %d, want 3700", tt.name, snap.Uptime)
+		}
+	}
+
+	srv := newAgent(t, "")
+	_, err := fetchSnapshot(client, srv.Listener.Addr().String()+"/missing")
+	if err == nil {
+		t.Errorf("fetchSnapshot() accepted a 404")
+	}
+}
+
+func TestCollectTable(t *testing.T) {
+	vars := &varsT{tempUnit: "C", collectStale: time.Minute}
+	client := &http.Client{Timeout: time.Second}
+
+	srv := newAgent(t, snapshotJson(t, schemaVersion))
+	host := &collectHostT{addr: srv.Listener.Addr().String()}
+
+	// a host that was never reached
+	down := httptest.NewServer(http.NotFoundHandler())
+	down.Close()
+	never := &collectHostT{addr: down.Listener.Addr().String()}
+
+	hosts := []*collectHostT{host, never}
+
+	status := func() (string, string) {
+		lines := str.Split(captureStdout(t, func() {
+			printCollectTable(hosts, vars)
+		}), "\n")
+		if len(lines) < 3 {
+			t.Fatalf("table %q", lines)
+		}
+		return lines[1], lines[2]
+	}
+
+	pollHosts(client, hosts)
+	line, neverLine := status()
+	if host.err != nil || host.snap == nil {
+		t.Fatalf("poll of a running agent: %v", host.err)
+	}
+	if fs := str.Fields(line); len(fs) < 8 || fs[1] != "ok" ||
+		fs[2] != "1h1m" || fs[3] != "0.42" || fs[4] != "25%" ||
+		fs[5] != "2.0" || fs[7] != "55°C" {
+		t.Errorf("ok line %q", line)
+	}
+	if fs := str.Fields(neverLine); len(fs) < 3 || fs[1] != "unreachable" ||
+		fs[2] != "-" {
+		t.Errorf("never reached line %q", neverLine)
+	}
+
+	// the previous values are shown until collect_stale passes
+	srv.Close()
+	pollHosts(client, hosts)
+	line, _ = status()
+	if host.err == nil || host.snap == nil {
+		t.Fatalf("poll of a stopped agent: err %v snap %v", host.err,
+			host.snap)
+	}
+	if fs := str.Fields(line); len(fs) < 5 || fs[1] != "stale" ||
+		fs[4] != "0.42" {
+		t.Errorf("stale line %q", line)
+	}
+
+	host.last = time.Now().Add(-2 * time.Minute)
+	line, _ = status()
+	if fs := str.Fields(line); len(fs) < 3 || fs[1] != "unreachable" ||
+		fs[2] != "-" {
+		t.Errorf("unreachable line %q", line)
+	}
+}
@@ -23,8 +23,14 @@ func elInSlice(sl []string, el string) bool {
 	return false
 }
 
+// exitHook is run by errExit before exiting, e.g. to restore the terminal
+var exitHook func()
+
 func errExit(err error) {
 	if err != nil {
+		if exitHook != nil {
+			exitHook()
+		}
 		fmt.Fprintf(os.Stderr, "%s\n", err)
 		os.Exit(1)
 	}
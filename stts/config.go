@@ -4,6 +4,8 @@ import (
 	"bufio"
 	"fmt"
 	"os"
+	"strconv"
+	"time"
 
 	str "strings"
 )
@@ -28,46 +30,264 @@ func parseConfig(file string, vars *varsT) error {
 }
 
 func parseLine(line string, vars *varsT) {
-	fields := str.Split(line, "=")
+	key, val, found := str.Cut(line, "=")
 	errMsg := "incorrect config line: %s"
 
-	if len(fields) != 2 {
+	if !found {
 		errExit(fmt.Errorf(errMsg, line))
 	}
 
-	key := fields[0]
-	val := fields[1]
-
 	switch key {
+	case "cpu_usage":
+		vars.show.cpuUsage = getBoolVal(val, line)
+	case "disk":
+		if val == "" {
+			return
+		}
+		vars.diskMounts = append(vars.diskMounts,
+			parseDiskConf(val, line))
+	case "disk_io":
+		vars.show.diskIo = getBoolVal(val, line)
+	case "disk_io_dev":
+		if val == "" {
+			return
+		}
+		vars.diskIoDevs = append(vars.diskIoDevs, val)
+	case "temp_unit":
+		if val != "C" && val != "F" {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.tempUnit = val
+	case "temp_decimals":
+		dec, err := strconv.Atoi(val)
+		if err != nil || dec < 0 || dec > 3 {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.tempDecimals = dec
+	case "temp_agg":
+		vars.tempAggDefault = getTempAgg(val, line)
+	case "cpu_temp_agg":
+		if val == "" {
+			return
+		}
+		vars.tempAgg["cpu"] = getTempAgg(val, line)
+	case "mobo_temp_agg":
+		if val == "" {
+			return
+		}
+		vars.tempAgg["mobo"] = getTempAgg(val, line)
+	case "drive_temp_agg":
+		if val == "" {
+			return
+		}
+		vars.tempAgg["drive"] = getTempAgg(val, line)
+	case "gpu_temp_agg":
+		if val == "" {
+			return
+		}
+		vars.tempAgg["gpu"] = getTempAgg(val, line)
+	case "nvme_temp_agg":
+		if val == "" {
+			return
+		}
+		vars.tempAgg["nvme"] = getTempAgg(val, line)
+	case "temp_group":
+		if val == "" {
+			return
+		}
+		vars.tempGroupDefs = append(vars.tempGroupDefs,
+			parseTempGroup(val, line))
+	case "temp_map":
+		if val == "" {
+			return
+		}
+		vars.tempMaps = append(vars.tempMaps, parseTempMap(val, line))
+	case "hwmon":
+		vars.show.hwmon = getBoolVal(val, line)
 	case "cpu_temp":
 		vars.show.cpuTemp = getBoolVal(val, line)
 	case "mobo_temp":
 		vars.show.moboTemp = getBoolVal(val, line)
 	case "drive_temp":
 		vars.show.driveTemp = getBoolVal(val, line)
+	case "gpu":
+		vars.show.gpu = getBoolVal(val, line)
+	case "nvme_temp":
+		vars.show.nvmeTemp = getBoolVal(val, line)
+	case "net":
+		vars.show.net = getBoolVal(val, line)
+	case "net_iface":
+		if val == "" {
+			return
+		}
+		vars.netIfaceNames = append(vars.netIfaceNames, val)
 	case "wifi":
 		vars.show.wifi = getBoolVal(val, line)
+	case "wifi_iface":
+		if val == "" {
+			return
+		}
+		vars.wifiIfaceNames = append(vars.wifiIfaceNames, val)
+	case "wifi_scan":
+		vars.wifiScan = getBoolVal(val, line)
 	case "battery":
 		vars.show.bat = getBoolVal(val, line)
+	case "pressure":
+		vars.show.pressure = getBoolVal(val, line)
+	case "pressure_seg":
+		switch val {
+		case "":
+			return
+		case "psi", "faults", "swapio", "oom", "throttle":
+			vars.pressureSegs = append(vars.pressureSegs, val)
+		default:
+			errExit(fmt.Errorf(errMsg, line))
+		}
+	case "history_file":
+		vars.histFile = val
+	case "history_interval":
+		interval, err := strconv.Atoi(val)
+		if err != nil || interval < 1 {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.histInterval = time.Duration(interval) * time.Second
+	case "history_retention":
+		retention, err := parseRetention(val)
+		if err != nil || retention <= 0 {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.histRetention = retention
+	case "alert":
+		if val == "" {
+			return
+		}
+		rule, err := parseAlert(val)
+		if err != nil {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.alerts = append(vars.alerts, rule)
+	case "alert_cmd":
+		vars.alertCmd = val
+	case "alert_notify":
+		vars.alertNotify = val
+	case "alert_file":
+		vars.alertFile = val
+	case "collect_host":
+		if val == "" {
+			return
+		}
+		vars.collectHosts = append(vars.collectHosts, val)
+	case "collect_interval":
+		interval, err := strconv.Atoi(val)
+		if err != nil || interval < 1 {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.collectInterval = time.Duration(interval) * time.Second
+	case "collect_stale":
+		stale, err := strconv.Atoi(val)
+		if err != nil || stale < 1 {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.collectStale = time.Duration(stale) * time.Second
 	case "add_info":
 		if val == "" {
 			return
 		}
+		// the file can be created later, e.g. the alert_file
 		fd, err := os.Open(val)
-		errExit(err)
+		if err != nil && !os.IsNotExist(err) {
+			errExit(err)
+		}
 		vars.addInfoFd = append(vars.addInfoFd, fd)
+		vars.addInfoFiles = append(vars.addInfoFiles, val)
 	case "vpn_route":
 		if val == "" {
 			return
 		}
 		vars.vpnRoute = val
 		vars.show.vpn = true
+	case "vpn_iface":
+		if val == "" {
+			return
+		}
+		vars.vpnIface = val
+		vars.show.vpn = true
 	case "vpn_pid":
 		if val == "" {
 			return
 		}
 		vars.vpnPidFile = val
 		vars.show.vpn = true
+	case "load_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.load.setWarn(getFloatVal(val, line))
+	case "load_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.load.setCrit(getFloatVal(val, line))
+	case "mem_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.mem.setWarn(getFloatVal(val, line))
+	case "mem_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.mem.setCrit(getFloatVal(val, line))
+	case "df_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.disk.setWarn(getFloatVal(val, line))
+	case "df_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.disk.setCrit(getFloatVal(val, line))
+	case "temp_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.temp.setWarn(getFloatVal(val, line))
+	case "temp_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.temp.setCrit(getFloatVal(val, line))
+	case "wifi_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.wifi.setWarn(getFloatVal(val, line))
+	case "wifi_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.wifi.setCrit(getFloatVal(val, line))
+	case "bat_warn":
+		if val == "" {
+			return
+		}
+		vars.limits.bat.setWarn(getFloatVal(val, line))
+	case "bat_crit":
+		if val == "" {
+			return
+		}
+		vars.limits.bat.setCrit(getFloatVal(val, line))
+	case "color_warn":
+		vars.colorWarn = val
+	case "color_crit":
+		vars.colorCrit = val
+	case "click":
+		block, cmd, found := str.Cut(val, ":")
+		if !found || block == "" || cmd == "" {
+			errExit(fmt.Errorf(errMsg, line))
+		}
+		vars.clickCmds[block] = cmd
 	default:
 		errExit(fmt.Errorf("incorrect config line: %s", line))
 	}
@@ -84,3 +304,21 @@ func getBoolVal(val, line string) bool {
 	}
 	return true
 }
+
+func getTempAgg(val, line string) string {
+	switch val {
+	case "max", "avg", "all":
+		return val
+	default:
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+	return val
+}
+
+func getFloatVal(val, line string) float64 {
+	f, err := strconv.ParseFloat(val, 64)
+	if err != nil {
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+	return f
+}
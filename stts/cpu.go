@@ -0,0 +1,218 @@
+package main
+
+import (
+	"bufio"
+	"os"
+	"sort"
+	"strconv"
+	"time"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+// shortest interval between two /proc/stat samples that still gives
+// a meaningful usage figure
+const cpuSampleMin = 200 * time.Millisecond
+
+type cpuT struct {
+	total cpuUsageT
+	cores []cpuUsageT
+
+	// current frequency in MHz by core name, e.g. cpu3
+	freqs map[string]int
+}
+
+type cpuUsageT struct {
+	name   string
+	busy   float64
+	user   float64
+	system float64
+	iowait float64
+	steal  float64
+}
+
+type cpuTicksT struct {
+	name    string
+	user    uint64
+	nice    uint64
+	system  uint64
+	idle    uint64
+	iowait  uint64
+	irq     uint64
+	softirq uint64
+	steal   uint64
+}
+
+func detectCpu(vars *varsT) {
+	var err error
+	vars.procStatFd, err = os.Open("/proc/stat")
+	if err != nil {
+		vars.procStatFd = nil
+		return
+	}
+
+	// glob sorts cpu10 before cpu2
+	freqFiles, _ := fp.Glob(
+		"/sys/devices/system/cpu/cpu[0-9]*/cpufreq/scaling_cur_freq")
+	sort.Slice(freqFiles, func(i, j int) bool {
+		return cpuNum(freqCpu(freqFiles[i])) < cpuNum(freqCpu(freqFiles[j]))
+	})
+	vars.cpuFreqFds = openFiles(freqFiles)
+	for _, fd := range vars.cpuFreqFds {
+		vars.cpuFreqCpus = append(vars.cpuFreqCpus, freqCpu(fd.Name()))
+	}
+
+	// prime the sampler so the first getCpuInfo call has a base
+	vars.cpuTicks = readCpuTicks(vars)
+	vars.cpuTicksTime = time.Now()
+}
+
+func getCpuInfo(st *sttsT, vars *varsT) {
+	if vars.procStatFd == nil {
+		return
+	}
+
+	since := time.Since(vars.cpuTicksTime)
+	if !vars.bench && since < cpuSampleMin {
+		time.Sleep(cpuSampleMin - since)
+	}
+
+	ticks := readCpuTicks(vars)
+	if len(ticks) == 0 {
+		return
+	}
+
+	st.cpu.cores = st.cpu.cores[:0]
+	for i, t := range ticks {
+		var usage cpuUsageT
+		if i < len(vars.cpuTicks) && vars.cpuTicks[i].name == t.name {
+			usage = getCpuUsage(vars.cpuTicks[i], t)
+		}
+		usage.name = t.name
+
+		if i == 0 {
+			st.cpu.total = usage
+		} else {
+			st.cpu.cores = append(st.cpu.cores, usage)
+		}
+	}
+
+	vars.cpuTicks = ticks
+	vars.cpuTicksTime = time.Now()
+
+	// offline cores can't be read and are left out
+	st.cpu.freqs = make(map[string]int)
+	for i, fd := range vars.cpuFreqFds {
+		khz, err := readSysInt(fd, vars)
+		if err != nil {
+			continue
+		}
+		st.cpu.freqs[vars.cpuFreqCpus[i]] = int(khz / 1000)
+	}
+}
+
+// freqCpu returns the core of a cpufreq file, e.g. cpu3 of
+// /sys/devices/system/cpu/cpu3/cpufreq/scaling_cur_freq
+func freqCpu(file string) string {
+	return fp.Base(fp.Dir(fp.Dir(file)))
+}
+
+// cpuNum returns the number of a core name like cpu3, -1 for the aggregate
+func cpuNum(name string) int {
+	num, err := strconv.Atoi(str.TrimPrefix(name, "cpu"))
+	if err != nil {
+		return -1
+	}
+	return num
+}
+
+// readCpuTicks returns the aggregate cpu line first, followed by one entry
+// per core
+func readCpuTicks(vars *varsT) []cpuTicksT {
+	var ticks []cpuTicksT
+
+	rd := bufio.NewReaderSize(vars.procStatFd, 4096)
+	for {
+		lineBin, _, err := rd.ReadLine()
+		if err != nil {
+			break
+		}
+
+		fields := str.Fields(string(lineBin))
+		if len(fields) < 9 || !str.HasPrefix(fields[0], "cpu") {
+			break
+		}
+
+		var vals [8]uint64
+		for i := range vals {
+			vals[i], _ = strconv.ParseUint(fields[i+1], 10, 64)
+		}
+
+		ticks = append(ticks, cpuTicksT{
+			name:    fields[0],
+			user:    vals[0],
+			nice:    vals[1],
+			system:  vals[2],
+			idle:    vals[3],
+			iowait:  vals[4],
+			irq:     vals[5],
+			softirq: vals[6],
+			steal:   vals[7],
+		})
+	}
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		vars.procStatFd.Seek(0, 0)
+	}
+
+	return ticks
+}
+
+func getCpuUsage(prev, cur cpuTicksT) cpuUsageT {
+	var usage cpuUsageT
+
+	user := tickDelta(prev.user, cur.user) + tickDelta(prev.nice, cur.nice)
+	system := tickDelta(prev.system, cur.system) +
+		tickDelta(prev.irq, cur.irq) +
+		tickDelta(prev.softirq, cur.softirq)
+	idle := tickDelta(prev.idle, cur.idle)
+	iowait := tickDelta(prev.iowait, cur.iowait)
+	steal := tickDelta(prev.steal, cur.steal)
+
+	total := user + system + idle + iowait + steal
+	if total <= 0 {
+		return usage
+	}
+
+	usage.user = user / total * 100
+	usage.system = system / total * 100
+	usage.iowait = iowait / total * 100
+	usage.steal = steal / total * 100
+	usage.busy = usage.user + usage.system + usage.steal
+
+	return usage
+}
+
+// tickDelta guards against counters going backwards, which the kernel
+// allows for iowait
+func tickDelta(prev, cur uint64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur - prev)
+}
+
+func avgCpuFreq(freqs map[string]int) int {
+	if len(freqs) == 0 {
+		return 0
+	}
+
+	var sum int
+	for _, f := range freqs {
+		sum += f
+	}
+
+	return sum / len(freqs)
+}
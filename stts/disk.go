@@ -1,12 +1,182 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
 	"syscall"
+
+	str "strings"
 )
 
+type diskT struct {
+	path string
+
+	// bytes; free is the space available to unprivileged users
+	total uint64
+	used  uint64
+	free  uint64
+
+	inodes     uint64
+	inodesFree uint64
+
+	limit thresholdT
+}
+
+type diskMountT struct {
+	path  string
+	limit thresholdT
+}
+
+// filesystems never worth reporting free space for
+var pseudoFs = []string{
+	"autofs", "binfmt_misc", "bpf", "cgroup", "cgroup2", "configfs",
+	"debugfs", "devpts", "devtmpfs", "efivarfs", "fusectl", "hugetlbfs",
+	"mqueue", "nsfs", "proc", "pstore", "ramfs", "rpc_pipefs",
+	"securityfs", "selinuxfs", "squashfs", "sysfs", "tmpfs", "tracefs",
+	"fuse.gvfsd-fuse", "fuse.portal",
+}
+
+func detectDisks(vars *varsT) {
+	if len(vars.diskMounts) > 0 {
+		vars.diskConf = true
+	} else {
+		vars.diskMounts = readMounts()
+	}
+
+	// mounts without their own thresholds use df_warn and df_crit
+	for i := range vars.diskMounts {
+		limit := &vars.diskMounts[i].limit
+		if !limit.hasWarn && !limit.hasCrit {
+			*limit = vars.limits.disk
+		}
+	}
+}
+
+func readMounts() []diskMountT {
+	var mounts []diskMountT
+	var devs []string
+
+	fd, err := os.Open("/proc/self/mounts")
+	if err != nil {
+		return []diskMountT{{path: "/"}}
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		dev := fields[0]
+		path := unescapeMount(fields[1])
+		fsType := fields[2]
+
+		if elInSlice(pseudoFs, fsType) {
+			continue
+		}
+
+		// skip bind mounts and btrfs subvolumes of an already seen device
+		if str.HasPrefix(dev, "/") && elInSlice(devs, dev) {
+			continue
+		}
+		devs = append(devs, dev)
+
+		mounts = append(mounts, diskMountT{path: path})
+	}
+
+	return mounts
+}
+
+// unescapeMount decodes the octal escapes /proc/self/mounts uses for spaces,
+// tabs, newlines and backslashes in paths
+func unescapeMount(s string) string {
+	if !str.Contains(s, `\`) {
+		return s
+	}
+
+	var sb str.Builder
+	for i := 0; i < len(s); i++ {
+		if s[i] == '\\' && i+3 < len(s) {
+			val, err := strconv.ParseUint(s[i+1:i+4], 8, 8)
+			if err == nil {
+				sb.WriteByte(byte(val))
+				i += 3
+				continue
+			}
+		}
+		sb.WriteByte(s[i])
+	}
+
+	return sb.String()
+}
+
 func getDiskInfo(st *sttsT, vars *varsT) {
 	var fsInfo syscall.Statfs_t
 	syscall.Statfs("/", &fsInfo)
 	st.rootDiskFree = float64(fsInfo.Bavail * uint64(fsInfo.Bsize))
 	st.rootDiskFree /= 1024 * 1024
+
+	st.disks = st.disks[:0]
+	for _, mount := range vars.diskMounts {
+		err := syscall.Statfs(mount.path, &fsInfo)
+		if err != nil {
+			continue
+		}
+
+		bsize := uint64(fsInfo.Bsize)
+		st.disks = append(st.disks, diskT{
+			path:       mount.path,
+			total:      fsInfo.Blocks * bsize,
+			used:       (fsInfo.Blocks - fsInfo.Bfree) * bsize,
+			free:       fsInfo.Bavail * bsize,
+			inodes:     fsInfo.Files,
+			inodesFree: fsInfo.Ffree,
+			limit:      mount.limit,
+		})
+	}
+}
+
+func (d diskT) freeMB() float64 {
+	return float64(d.free) / (1024 * 1024)
+}
+
+func (d diskT) inodesUsedPerc() float64 {
+	if d.inodes == 0 {
+		return 0
+	}
+	return float64(d.inodes-d.inodesFree) / float64(d.inodes) * 100
+}
+
+func fmtDiskMount(d diskT) string {
+	free := d.freeMB()
+	if free > 1024 {
+		return fmt.Sprintf("df %s %.1fG", d.path, free/1024)
+	}
+	return fmt.Sprintf("df %s %.0fM", d.path, free)
+}
+
+// parseDiskConf reads a disk config value in the form of
+// path[:warn[:crit]], with thresholds in MB of free space
+func parseDiskConf(val, line string) diskMountT {
+	fields := str.Split(val, ":")
+	if len(fields) > 3 || fields[0] == "" {
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+
+	mount := diskMountT{path: fields[0]}
+	mount.limit.low = true
+
+	if len(fields) > 1 && fields[1] != "" {
+		mount.limit.setWarn(getFloatVal(fields[1], line))
+	}
+
+	if len(fields) > 2 && fields[2] != "" {
+		mount.limit.setCrit(getFloatVal(fields[2], line))
+	}
+
+	return mount
 }
@@ -0,0 +1,52 @@
+package main
+
+import "testing"
+
+func TestUnescapeMount(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"/", "/"},
+		{"/mnt/data", "/mnt/data"},
+		{`/mnt/my\040disk`, "/mnt/my disk"},
+		{`/mnt/a\011b\012c`, "/mnt/a\tb\nc"},
+		{`/mnt/back\134slash`, `/mnt/back\slash`},
+		{`/mnt/end\040`, "/mnt/end "},
+		{`/mnt/short\04`, `/mnt/short\04`},
+		{`/mnt/not\999octal`, `/mnt/not\999octal`},
+		{`/mnt/trailing\`, `/mnt/trailing\`},
+	}
+
+	for _, tt := range tests {
+		if got := unescapeMount(tt.in); got != tt.want {
+			t.Errorf("unescapeMount(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestParseDiskConf(t *testing.T) {
+	tests := []struct {
+		val  string
+		want diskMountT
+	}{
+		{"/home", diskMountT{path: "/home",
+			limit: thresholdT{low: true}}},
+		{"/home:4096", diskMountT{path: "/home",
+			limit: thresholdT{warn: 4096, hasWarn: true, low: true}}},
+		{"/home:4096:1024", diskMountT{path: "/home",
+			limit: thresholdT{warn: 4096, crit: 1024, hasWarn: true,
+				hasCrit: true, low: true}}},
+		{"/home::512", diskMountT{path: "/home",
+			limit: thresholdT{crit: 512, hasCrit: true, low: true}}},
+		{"/home:", diskMountT{path: "/home",
+			limit: thresholdT{low: true}}},
+	}
+
+	for _, tt := range tests {
+		got := parseDiskConf(tt.val, "disk="+tt.val)
+		if got != tt.want {
+			t.Errorf("parseDiskConf(%q) = %+v, want %+v", tt.val, got,
+				tt.want)
+		}
+	}
+}
@@ -0,0 +1,150 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"strconv"
+	"time"
+
+	str "strings"
+)
+
+type diskIoT struct {
+	name string
+
+	// per second since the previous sample
+	readBytes  float64
+	writeBytes float64
+	readOps    float64
+	writeOps   float64
+
+	inFlight uint64
+
+	// average time in ms a request spent queued and serviced
+	await float64
+}
+
+type diskIoTicksT struct {
+	name         string
+	reads        uint64
+	readSectors  uint64
+	readMs       uint64
+	writes       uint64
+	writeSectors uint64
+	writeMs      uint64
+	inFlight     uint64
+}
+
+func detectDiskIo(vars *varsT) {
+	var err error
+	vars.diskstatsFd, err = os.Open("/proc/diskstats")
+	if err != nil {
+		vars.diskstatsFd = nil
+		return
+	}
+
+	vars.diskIoTicks = readDiskIoTicks(vars)
+	vars.diskIoTime = time.Now()
+}
+
+func getDiskIoInfo(st *sttsT, vars *varsT) {
+	if vars.diskstatsFd == nil {
+		return
+	}
+
+	waitSample(vars.diskIoTime, vars)
+
+	ticks := readDiskIoTicks(vars)
+	elapsed := time.Since(vars.diskIoTime).Seconds()
+
+	st.diskIo = st.diskIo[:0]
+	for _, t := range ticks {
+		io := diskIoT{name: t.name, inFlight: t.inFlight}
+		for _, prev := range vars.diskIoTicks {
+			if prev.name == t.name && elapsed > 0 {
+				io = getDiskIo(prev, t, elapsed)
+				break
+			}
+		}
+		st.diskIo = append(st.diskIo, io)
+	}
+
+	vars.diskIoTicks = ticks
+	vars.diskIoTime = time.Now()
+}
+
+func readDiskIoTicks(vars *varsT) []diskIoTicksT {
+	var ticks []diskIoTicksT
+
+	rd := bufio.NewReaderSize(vars.diskstatsFd, 4096)
+	for {
+		lineBin, _, err := rd.ReadLine()
+		if err != nil {
+			break
+		}
+
+		fields := str.Fields(string(lineBin))
+		if len(fields) < 14 || !diskIoShown(fields[2]) {
+			continue
+		}
+
+		var vals [11]uint64
+		for i := range vals {
+			vals[i], _ = strconv.ParseUint(fields[i+3], 10, 64)
+		}
+
+		ticks = append(ticks, diskIoTicksT{
+			name:         fields[2],
+			reads:        vals[0],
+			readSectors:  vals[2],
+			readMs:       vals[3],
+			writes:       vals[4],
+			writeSectors: vals[6],
+			writeMs:      vals[7],
+			inFlight:     vals[8],
+		})
+	}
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		vars.diskstatsFd.Seek(0, 0)
+	}
+
+	return ticks
+}
+
+// diskIoShown filters out partitions and loop and ram devices
+func diskIoShown(name string) bool {
+	if str.HasPrefix(name, "loop") || str.HasPrefix(name, "ram") {
+		return false
+	}
+	return fileExists("/sys/block/" + name)
+}
+
+func getDiskIo(prev, cur diskIoTicksT, elapsed float64) diskIoT {
+	io := diskIoT{name: cur.name, inFlight: cur.inFlight}
+
+	// diskstats sectors are always 512 bytes regardless of the device
+	io.readBytes = counterRate(prev.readSectors, cur.readSectors,
+		elapsed) * 512
+	io.writeBytes = counterRate(prev.writeSectors, cur.writeSectors,
+		elapsed) * 512
+	io.readOps = counterRate(prev.reads, cur.reads, elapsed)
+	io.writeOps = counterRate(prev.writes, cur.writes, elapsed)
+
+	ops := tickDelta(prev.reads, cur.reads) +
+		tickDelta(prev.writes, cur.writes)
+	ms := tickDelta(prev.readMs, cur.readMs) +
+		tickDelta(prev.writeMs, cur.writeMs)
+	if ops > 0 {
+		io.await = ms / ops
+	}
+
+	return io
+}
+
+func fmtDiskIo(io diskIoT) string {
+	return fmt.Sprintf("%s r%s w%s", io.name,
+		fmtBytes(io.readBytes), fmtBytes(io.writeBytes))
+}
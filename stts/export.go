@@ -0,0 +1,788 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+// version of the json and key=value output schema; bump on any change that
+// renames or removes a field; 5 keys array elements of the key=value output
+// by name instead of index
+const schemaVersion = 5
+
+type exportT struct {
+	Version    int           `json:"version"`
+	Uptime     int64         `json:"uptime_sec"`
+	Loads      [3]float64    `json:"loads"`
+	Procs      int           `json:"procs"`
+	Cpu        *exportCpuT   `json:"cpu,omitempty"`
+	Mem        exportMemT    `json:"mem"`
+	RootDiskMB float64       `json:"root_disk_free_mb"`
+	Disks      []exportDiskT `json:"disks"`
+	DiskIo     []exportIoT   `json:"disk_io"`
+	Temps      []exportTempT `json:"temps"`
+	Sensors    []exportSensT `json:"sensors"`
+	Gpus       []exportGpuT  `json:"gpus"`
+	Net        []exportNetT  `json:"net"`
+	Wifi       []exportWifiT `json:"wifi"`
+	Bat        *exportBatT   `json:"battery,omitempty"`
+	Vpn        *exportVpnT   `json:"vpn,omitempty"`
+	Pressure   *exportPresT  `json:"pressure,omitempty"`
+	AddInfo    []string      `json:"add_info"`
+	Ps         []exportProcT `json:"processes,omitempty"`
+	Cgroups    []exportCgT   `json:"cgroups,omitempty"`
+	Sockets    []exportSockT `json:"sockets,omitempty"`
+}
+
+type exportCpuT struct {
+	exportCpuUsageT
+	Cores []exportCoreT `json:"cores"`
+}
+
+// the frequency is left out for cores without cpufreq
+type exportCoreT struct {
+	Name string `json:"name"`
+	exportCpuUsageT
+	Freq *int `json:"freq_mhz,omitempty"`
+}
+
+type exportCpuUsageT struct {
+	Busy   float64 `json:"busy"`
+	User   float64 `json:"user"`
+	System float64 `json:"system"`
+	Iowait float64 `json:"iowait"`
+	Steal  float64 `json:"steal"`
+}
+
+type exportMemT struct {
+	Total  int `json:"total"`
+	Used   int `json:"used"`
+	Free   int `json:"free"`
+	Shared int `json:"shared"`
+	Buffer int `json:"buffer"`
+	Cache  int `json:"cache"`
+	Avail  int `json:"avail"`
+	Huge   int `json:"huge"`
+
+	SwapTotal  int `json:"swap_total"`
+	SwapUsed   int `json:"swap_used"`
+	SwapFree   int `json:"swap_free"`
+	SwapCached int `json:"swap_cached"`
+	Dirty      int `json:"dirty"`
+	Writeback  int `json:"writeback"`
+	AnonHuge   int `json:"anon_huge"`
+
+	ThpMode  string        `json:"thp_mode"`
+	Pressure string        `json:"pressure"`
+	Zram     []exportZramT `json:"zram"`
+}
+
+type exportZramT struct {
+	Name     string  `json:"name"`
+	DiskSize uint64  `json:"disk_size"`
+	Orig     uint64  `json:"orig_data"`
+	Compr    uint64  `json:"compr_data"`
+	MemUsed  uint64  `json:"mem_used"`
+	Ratio    float64 `json:"ratio"`
+}
+
+type exportDiskT struct {
+	Path       string `json:"path"`
+	Total      uint64 `json:"total"`
+	Used       uint64 `json:"used"`
+	Free       uint64 `json:"free"`
+	Inodes     uint64 `json:"inodes"`
+	InodesFree uint64 `json:"inodes_free"`
+}
+
+type exportIoT struct {
+	Name       string  `json:"name"`
+	ReadBytes  float64 `json:"read_bytes_per_sec"`
+	WriteBytes float64 `json:"write_bytes_per_sec"`
+	ReadOps    float64 `json:"read_iops"`
+	WriteOps   float64 `json:"write_iops"`
+	InFlight   uint64  `json:"in_flight"`
+	Await      float64 `json:"await_ms"`
+}
+
+// attributes the driver doesn't provide are left out
+type exportGpuT struct {
+	Name      string   `json:"name"`
+	Driver    string   `json:"driver"`
+	Fan       *int64   `json:"fan_rpm,omitempty"`
+	Power     *float64 `json:"power_watts,omitempty"`
+	Busy      *int64   `json:"busy,omitempty"`
+	VramUsed  *int64   `json:"vram_used,omitempty"`
+	VramTotal *int64   `json:"vram_total,omitempty"`
+}
+
+// temperatures are always in °C regardless of temp_unit
+type exportTempT struct {
+	Group    string    `json:"group"`
+	Label    string    `json:"label"`
+	Max      float64   `json:"max"`
+	Avg      float64   `json:"avg"`
+	Readings []float64 `json:"readings"`
+}
+
+type exportNetT struct {
+	Name    string   `json:"name"`
+	State   string   `json:"state"`
+	Addrs   []string `json:"addrs"`
+	RxBytes uint64   `json:"rx_bytes"`
+	TxBytes uint64   `json:"tx_bytes"`
+	RxRate  float64  `json:"rx_bytes_per_sec"`
+	TxRate  float64  `json:"tx_bytes_per_sec"`
+}
+
+type exportSensT struct {
+	Chip  string   `json:"chip"`
+	Hwmon string   `json:"hwmon"`
+	Kind  string   `json:"kind"`
+	Label string   `json:"label"`
+	Value float64  `json:"value"`
+	Unit  string   `json:"unit"`
+	Crit  *float64 `json:"crit,omitempty"`
+	Max   *float64 `json:"max,omitempty"`
+}
+
+type exportWifiT struct {
+	Iface      string `json:"iface"`
+	Connected  bool   `json:"connected"`
+	SSID       string `json:"ssid"`
+	BSSID      string `json:"bssid"`
+	Frequency  int    `json:"frequency_mhz"`
+	Signal     int    `json:"signal_dbm"`
+	TxBitrate  int    `json:"tx_bitrate_bps"`
+	RxBitrate  int    `json:"rx_bitrate_bps"`
+	ConnectedT int64  `json:"connected_sec"`
+}
+
+// pid_alive and route_found are only set when configured
+type exportVpnT struct {
+	Up         bool    `json:"up"`
+	PidAlive   *bool   `json:"pid_alive,omitempty"`
+	RouteFound *bool   `json:"route_found,omitempty"`
+	Iface      string  `json:"iface,omitempty"`
+	RxBytes    uint64  `json:"rx_bytes"`
+	TxBytes    uint64  `json:"tx_bytes"`
+	RxRate     float64 `json:"rx_bytes_per_sec"`
+	TxRate     float64 `json:"tx_bytes_per_sec"`
+}
+
+// psi resources and throttle counts the kernel doesn't provide are left out
+type exportPresT struct {
+	Cpu          *exportPsiT `json:"cpu,omitempty"`
+	Mem          *exportPsiT `json:"memory,omitempty"`
+	Io           *exportPsiT `json:"io,omitempty"`
+	MajFaults    uint64      `json:"major_faults"`
+	MajFaultRate float64     `json:"major_faults_per_sec"`
+	OomKills     uint64      `json:"oom_kills"`
+	SwapIn       uint64      `json:"swap_in_pages"`
+	SwapOut      uint64      `json:"swap_out_pages"`
+	SwapInRate   float64     `json:"swap_in_pages_per_sec"`
+	SwapOutRate  float64     `json:"swap_out_pages_per_sec"`
+	CoreThrottle *uint64     `json:"core_throttle_count,omitempty"`
+	PkgThrottle  *uint64     `json:"package_throttle_count,omitempty"`
+}
+
+type exportPsiT struct {
+	Some exportPsiAvgT `json:"some"`
+	Full exportPsiAvgT `json:"full"`
+}
+
+type exportPsiAvgT struct {
+	Avg10  float64 `json:"avg10"`
+	Avg60  float64 `json:"avg60"`
+	Avg300 float64 `json:"avg300"`
+	Total  uint64  `json:"total_usec"`
+}
+
+type exportBatT struct {
+	Level     int             `json:"level"`
+	TimeLeft  string          `json:"time_left"`
+	AcOnline  bool            `json:"ac_online"`
+	Batteries []exportBatDevT `json:"batteries"`
+}
+
+// charge thresholds the firmware doesn't support are left out
+type exportBatDevT struct {
+	Name        string  `json:"name"`
+	Status      string  `json:"status"`
+	Level       int     `json:"level"`
+	Cycles      int     `json:"cycle_count"`
+	Wear        float64 `json:"wear"`
+	ChargeStart *int    `json:"charge_start,omitempty"`
+	ChargeEnd   *int    `json:"charge_stop,omitempty"`
+}
+
+type exportProcT struct {
+	Pid        int      `json:"pid"`
+	Ppid       int      `json:"ppid"`
+	Uid        int      `json:"uid"`
+	State      string   `json:"state"`
+	Comm       string   `json:"comm"`
+	Bin        string   `json:"bin"`
+	Args       string   `json:"args"`
+	Pwd        string   `json:"pwd"`
+	ReadBytes  int      `json:"read_bytes"`
+	WriteBytes int      `json:"write_bytes"`
+	FdCount    int      `json:"fd_count"`
+	Cpu        float64  `json:"cpu"`
+	RssBytes   int64    `json:"rss_bytes"`
+	Threads    int      `json:"threads"`
+	Files      []string `json:"files,omitempty"`
+	Env        []string `json:"env,omitempty"`
+}
+
+// pids are the matching processes directly in the cgroup
+type exportCgT struct {
+	Path       string  `json:"path"`
+	Unit       string  `json:"unit"`
+	User       string  `json:"user"`
+	Cpu        float64 `json:"cpu"`
+	MemBytes   int64   `json:"mem_bytes"`
+	ReadBytes  uint64  `json:"read_bytes"`
+	WriteBytes uint64  `json:"write_bytes"`
+	IoRate     float64 `json:"io_bytes_per_sec"`
+	PidCount   int64   `json:"pid_count"`
+	Pids       []int   `json:"pids"`
+}
+
+// pid is 0 for the sockets of processes that can't be read
+type exportSockT struct {
+	Pid      int               `json:"pid"`
+	User     string            `json:"user"`
+	Cmd      string            `json:"cmd"`
+	UnixConn int               `json:"unix_other"`
+	Socks    []exportSockAddrT `json:"sockets"`
+}
+
+type exportSockAddrT struct {
+	Proto  string `json:"proto"`
+	State  string `json:"state"`
+	Local  string `json:"local"`
+	Remote string `json:"remote"`
+}
+
+func printExport(st *sttsT, vars *varsT) {
+	ex := getExport(st, vars)
+
+	switch vars.format {
+	case "json":
+		out, err := json.Marshal(ex)
+		errExit(err)
+		fmt.Printf("%s\n", out)
+	case "kv":
+		printKv(ex)
+	}
+}
+
+func getExport(st *sttsT, vars *varsT) exportT {
+	var ex exportT
+
+	ex.Version = schemaVersion
+	ex.Uptime = int64(st.uptime.Seconds())
+	ex.Loads = st.loads
+	ex.Procs = st.procs
+
+	if vars.has.cpuUsage {
+		ex.Cpu = &exportCpuT{
+			exportCpuUsageT: getExportCpuUsage(st.cpu.total),
+			Cores:           []exportCoreT{},
+		}
+		for _, core := range st.cpu.cores {
+			ec := exportCoreT{
+				Name:            core.name,
+				exportCpuUsageT: getExportCpuUsage(core),
+			}
+			if freq, ok := st.cpu.freqs[core.name]; ok {
+				ec.Freq = &freq
+			}
+			ex.Cpu.Cores = append(ex.Cpu.Cores, ec)
+		}
+	}
+
+	ex.Mem = exportMemT{
+		Total:  st.mem.total,
+		Used:   st.mem.used,
+		Free:   st.mem.free,
+		Shared: st.mem.shared,
+		Buffer: st.mem.buffer,
+		Cache:  st.mem.cache,
+		Avail:  st.mem.avail,
+		Huge:   st.mem.huge,
+
+		SwapTotal:  st.mem.swapTotal,
+		SwapUsed:   st.mem.swapUsed,
+		SwapFree:   st.mem.swapFree,
+		SwapCached: st.mem.swapCached,
+		Dirty:      st.mem.dirty,
+		Writeback:  st.mem.writeback,
+		AnonHuge:   st.mem.anonHuge,
+
+		ThpMode: vars.thpMode,
+		Zram:    []exportZramT{},
+	}
+	ex.Mem.Pressure, _ = memPressure(st, vars)
+	for _, z := range st.mem.zram {
+		ex.Mem.Zram = append(ex.Mem.Zram, exportZramT{
+			Name:     z.name,
+			DiskSize: z.diskSize,
+			Orig:     z.orig,
+			Compr:    z.compr,
+			MemUsed:  z.memUsed,
+			Ratio:    z.ratio,
+		})
+	}
+	ex.RootDiskMB = st.rootDiskFree
+
+	ex.Disks = []exportDiskT{}
+	for _, d := range st.disks {
+		ex.Disks = append(ex.Disks, exportDiskT{
+			Path:       d.path,
+			Total:      d.total,
+			Used:       d.used,
+			Free:       d.free,
+			Inodes:     d.inodes,
+			InodesFree: d.inodesFree,
+		})
+	}
+
+	ex.DiskIo = []exportIoT{}
+	for _, io := range st.diskIo {
+		ex.DiskIo = append(ex.DiskIo, exportIoT{
+			Name:       io.name,
+			ReadBytes:  io.readBytes,
+			WriteBytes: io.writeBytes,
+			ReadOps:    io.readOps,
+			WriteOps:   io.writeOps,
+			InFlight:   io.inFlight,
+			Await:      io.await,
+		})
+	}
+
+	ex.Temps = []exportTempT{}
+	for _, t := range st.temps {
+		ex.Temps = append(ex.Temps, getExportTemp(t))
+	}
+
+	ex.Sensors = []exportSensT{}
+	for _, s := range st.sensors {
+		es := exportSensT{
+			Chip:  s.chip,
+			Hwmon: fp.Base(s.dir),
+			Kind:  s.kind,
+			Label: s.label,
+			Value: s.value,
+			Unit:  sensorUnit(s.kind),
+		}
+		if s.crit != 0 {
+			crit := s.crit
+			es.Crit = &crit
+		}
+		if s.max != 0 {
+			max := s.max
+			es.Max = &max
+		}
+		ex.Sensors = append(ex.Sensors, es)
+	}
+
+	ex.Gpus = []exportGpuT{}
+	for i, gpu := range st.gpus {
+		ex.Gpus = append(ex.Gpus, getExportGpu(gpu, vars.gpus[i]))
+	}
+
+	ex.Net = []exportNetT{}
+	for _, n := range st.net {
+		en := exportNetT{
+			Name:    n.name,
+			State:   n.state,
+			Addrs:   n.addrs,
+			RxBytes: n.rxBytes,
+			TxBytes: n.txBytes,
+			RxRate:  n.rxRate,
+			TxRate:  n.txRate,
+		}
+		if en.Addrs == nil {
+			en.Addrs = []string{}
+		}
+		ex.Net = append(ex.Net, en)
+	}
+
+	ex.Wifi = []exportWifiT{}
+	for _, w := range st.wifis {
+		ew := exportWifiT{Iface: w.iface}
+		if w.connected() {
+			ew.Connected = true
+			ew.SSID = w.bss.SSID
+			ew.BSSID = w.bss.BSSID.String()
+			ew.Frequency = w.bss.Frequency
+		}
+		if w.info != nil {
+			ew.Signal = w.info.Signal
+			ew.TxBitrate = w.info.TransmitBitrate
+			ew.RxBitrate = w.info.ReceiveBitrate
+			ew.ConnectedT = int64(w.info.Connected.Seconds())
+		}
+		ex.Wifi = append(ex.Wifi, ew)
+	}
+
+	if vars.has.bat {
+		ex.Bat = &exportBatT{
+			TimeLeft: st.batTimeLeft,
+			AcOnline: st.acOnline,
+		}
+		ex.Bat.Level, _ = strconv.Atoi(st.batLevel)
+
+		ex.Bat.Batteries = []exportBatDevT{}
+		for _, bat := range st.bats {
+			ex.Bat.Batteries = append(ex.Bat.Batteries,
+				getExportBat(bat))
+		}
+	}
+
+	if vars.show.vpn {
+		ex.Vpn = &exportVpnT{
+			Up:      st.vpn.up,
+			Iface:   st.vpn.iface,
+			RxBytes: st.vpn.rxBytes,
+			TxBytes: st.vpn.txBytes,
+			RxRate:  st.vpn.rxRate,
+			TxRate:  st.vpn.txRate,
+		}
+		if vars.vpnPidFile != "" {
+			ex.Vpn.PidAlive = &st.vpn.pidAlive
+		}
+		if vars.vpnRoute != "" {
+			ex.Vpn.RouteFound = &st.vpn.routeFound
+		}
+	}
+
+	if vars.show.pressure {
+		ex.Pressure = getExportPressure(st.pressure, vars)
+	}
+
+	ex.AddInfo = st.addInfo
+	if ex.AddInfo == nil {
+		ex.AddInfo = []string{}
+	}
+
+	for _, p := range st.ps {
+		if hideProcess(p, vars) {
+			continue
+		}
+
+		var ep exportProcT
+		ep.Pid, _ = strconv.Atoi(p.pid)
+		ep.Ppid = p.stat.ppid
+		ep.Uid = p.uid
+		ep.State = string(p.stat.state)
+		ep.Comm = p.stat.comm
+		ep.Bin = p.bin
+		ep.Args = p.args
+		ep.Pwd = p.pwd
+		ep.ReadBytes = p.readBytes
+		ep.WriteBytes = p.writeBytes
+		ep.FdCount = p.fdCount
+		ep.Cpu = p.cpu
+		ep.RssBytes = p.stat.rss * int64(os.Getpagesize())
+		ep.Threads = p.stat.num_threads
+
+		if vars.files {
+			ep.Files = p.files
+		}
+		if vars.env {
+			ep.Env = p.env
+		}
+
+		ex.Ps = append(ex.Ps, ep)
+	}
+
+	for _, cg := range st.cgroups {
+		ec := exportCgT{
+			Path:       cg.path,
+			Unit:       cg.unit,
+			User:       cg.user,
+			Cpu:        cg.cpu,
+			MemBytes:   cg.mem,
+			ReadBytes:  cg.ioRead,
+			WriteBytes: cg.ioWrite,
+			IoRate:     cg.ioRate,
+			PidCount:   cg.pids,
+			Pids:       []int{},
+		}
+		for _, p := range cg.procs {
+			ec.Pids = append(ec.Pids, p.stat.pid)
+		}
+		ex.Cgroups = append(ex.Cgroups, ec)
+	}
+
+	for _, ps := range st.socks {
+		es := exportSockT{
+			User:     ps.user,
+			Cmd:      ps.cmd,
+			UnixConn: ps.unixConn,
+			Socks:    []exportSockAddrT{},
+		}
+		es.Pid, _ = strconv.Atoi(ps.pid)
+		for _, s := range ps.socks {
+			es.Socks = append(es.Socks, exportSockAddrT{
+				Proto:  s.proto,
+				State:  s.state,
+				Local:  s.local,
+				Remote: s.remote,
+			})
+		}
+		ex.Sockets = append(ex.Sockets, es)
+	}
+
+	return ex
+}
+
+func getExportCpuUsage(usage cpuUsageT) exportCpuUsageT {
+	return exportCpuUsageT{
+		Busy:   usage.busy,
+		User:   usage.user,
+		System: usage.system,
+		Iowait: usage.iowait,
+		Steal:  usage.steal,
+	}
+}
+
+func getExportBat(bat batT) exportBatDevT {
+	eb := exportBatDevT{
+		Name:   bat.name,
+		Status: bat.status,
+		Level:  bat.level,
+		Cycles: bat.cycles,
+		Wear:   bat.wear,
+	}
+
+	if start, err := strconv.Atoi(bat.chargeStart); err == nil {
+		eb.ChargeStart = &start
+	}
+	if end, err := strconv.Atoi(bat.chargeEnd); err == nil {
+		eb.ChargeEnd = &end
+	}
+
+	return eb
+}
+
+func getExportGpu(gpu gpuT, dev gpuDevT) exportGpuT {
+	eg := exportGpuT{Name: gpu.name, Driver: gpu.driver}
+
+	if dev.fanFd != nil {
+		eg.Fan = &gpu.fan
+	}
+	if dev.powerFd != nil {
+		eg.Power = &gpu.power
+	}
+	if dev.busyFd != nil {
+		eg.Busy = &gpu.busy
+	}
+	if dev.vramUsedFd != nil {
+		eg.VramUsed = &gpu.vramUsed
+		eg.VramTotal = &gpu.vramTotal
+	}
+
+	return eg
+}
+
+func getExportPressure(p pressureT, vars *varsT) *exportPresT {
+	ep := &exportPresT{
+		MajFaults:    p.majFaults,
+		MajFaultRate: p.majFaultRate,
+		OomKills:     p.oomKills,
+		SwapIn:       p.swapIn,
+		SwapOut:      p.swapOut,
+		SwapInRate:   p.swapInRate,
+		SwapOutRate:  p.swapOutRate,
+	}
+
+	psis := make([]*exportPsiT, len(psiResources))
+	for i, psi := range []psiT{p.cpu, p.mem, p.io} {
+		if vars.psiFds[i] != nil {
+			psis[i] = &exportPsiT{
+				Some: getExportPsiAvg(psi.some, psi.someTotal),
+				Full: getExportPsiAvg(psi.full, psi.fullTotal),
+			}
+		}
+	}
+	ep.Cpu, ep.Mem, ep.Io = psis[0], psis[1], psis[2]
+
+	if len(vars.coreThrottleFds) > 0 {
+		ep.CoreThrottle = &p.coreThrottle
+	}
+	if len(vars.pkgThrottleFds) > 0 {
+		ep.PkgThrottle = &p.pkgThrottle
+	}
+
+	return ep
+}
+
+func getExportPsiAvg(avgs [3]float64, total uint64) exportPsiAvgT {
+	return exportPsiAvgT{
+		Avg10:  avgs[0],
+		Avg60:  avgs[1],
+		Avg300: avgs[2],
+		Total:  total,
+	}
+}
+
+func getExportTemp(t tempT) exportTempT {
+	temp := exportTempT{
+		Group:    t.name,
+		Label:    t.label,
+		Max:      float64(aggTemps(t.temps, "max")) / 1000,
+		Avg:      float64(aggTemps(t.temps, "avg")) / 1000,
+		Readings: []float64{},
+	}
+
+	for _, r := range t.temps {
+		temp.Readings = append(temp.Readings, float64(r)/1000)
+	}
+
+	return temp
+}
+
+// printKv flattens the json representation so both formats always carry
+// the same keys, e.g. mem.used=123 or temps.cpu1.max=54
+func printKv(ex exportT) {
+	kv := getKv(ex)
+
+	var keys []string
+	for k := range kv {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	// keep the version first so readers can check it before parsing
+	fmt.Printf("version=%s\n", kv["version"])
+	for _, k := range keys {
+		if k == "version" {
+			continue
+		}
+		fmt.Printf("%s=%s\n", k, kv[k])
+	}
+}
+
+// getKv flattens the json export into dotted keys, e.g. temps.cpu1.max
+func getKv(ex exportT) map[string]string {
+	bin, err := json.Marshal(ex)
+	errExit(err)
+
+	var tree interface{}
+	err = json.Unmarshal(bin, &tree)
+	errExit(err)
+
+	kv := make(map[string]string)
+	flatten("", tree, kv)
+
+	return kv
+}
+
+// getKvVals returns the numeric values of the key=value output, booleans as
+// 0 or 1; strings and processes are left out
+func getKvVals(st *sttsT, vars *varsT) map[string]float64 {
+	vals := make(map[string]float64)
+
+	for k, v := range getKv(getExport(st, vars)) {
+		if k == "version" || str.HasPrefix(k, "processes.") {
+			continue
+		}
+
+		switch v {
+		case "true":
+			vals[k] = 1
+		case "false":
+			vals[k] = 0
+		default:
+			val, err := strconv.ParseFloat(v, 64)
+			if err != nil {
+				continue
+			}
+			vals[k] = val
+		}
+	}
+
+	return vals
+}
+
+func flatten(prefix string, node interface{}, kv map[string]string) {
+	if prefix != "" {
+		prefix += "."
+	}
+
+	switch v := node.(type) {
+	case map[string]interface{}:
+		for k, child := range v {
+			flatten(prefix+k, child, kv)
+		}
+	case []interface{}:
+		// elements are keyed by name where they have one, so the keys of
+		// e.g. a disk stay the same when another one is mounted
+		seen := make(map[string]bool)
+		for i, child := range v {
+			key := elemKey(child)
+			if key == "" || seen[key] {
+				key = strconv.Itoa(i)
+			}
+			seen[key] = true
+			flatten(prefix+key, child, kv)
+		}
+	case float64:
+		kv[prefix[:len(prefix)-1]] = strconv.FormatFloat(v, 'f', -1, 64)
+	case string:
+		kv[prefix[:len(prefix)-1]] = strconv.Quote(v)
+	case bool:
+		kv[prefix[:len(prefix)-1]] = strconv.FormatBool(v)
+	}
+}
+
+// fields naming an array element in the order they are looked for
+var elemKeyFields = []string{"name", "iface", "path", "group", "pid"}
+
+// elemKey returns the name of an array element usable in a dotted key, e.g.
+// _home for the disk mounted at /home, or "" when it has none; sensors are
+// named by chip and label as neither is unique on its own
+func elemKey(node interface{}) string {
+	m, ok := node.(map[string]interface{})
+	if !ok {
+		return ""
+	}
+
+	var name string
+	for _, field := range elemKeyFields {
+		switch v := m[field].(type) {
+		case string:
+			name = v
+		case float64:
+			name = strconv.FormatFloat(v, 'f', -1, 64)
+		}
+		if name != "" {
+			break
+		}
+	}
+	if name == "" {
+		chip, _ := m["chip"].(string)
+		label, _ := m["label"].(string)
+		if chip != "" && label != "" {
+			name = chip + "_" + label
+		}
+	}
+
+	return str.Map(func(r rune) rune {
+		if r >= 'a' && r <= 'z' || r >= 'A' && r <= 'Z' ||
+			r >= '0' && r <= '9' || r == '-' || r == '_' {
+			return r
+		}
+		return '_'
+	}, name)
+}
@@ -0,0 +1,120 @@
+package main
+
+import (
+	"encoding/json"
+	"strconv"
+	"testing"
+)
+
+func TestFlatten(t *testing.T) {
+	tests := []struct {
+		in   string
+		want map[string]string
+	}{
+		{`{"a":1,"b":"x","c":true}`,
+			map[string]string{"a": "1", "b": `"x"`, "c": "true"}},
+		{`{"mem":{"used":123,"free":4.5}}`,
+			map[string]string{"mem.used": "123", "mem.free": "4.5"}},
+		{`{"loads":[0.5,1,2]}`,
+			map[string]string{"loads.0": "0.5", "loads.1": "1",
+				"loads.2": "2"}},
+		{`{"s":"a \"q\"\n"}`,
+			map[string]string{"s": `"a \"q\"\n"`}},
+		{`{"big":12345678901234}`,
+			map[string]string{"big": "12345678901234"}},
+		{`{"empty":[],"none":null}`, map[string]string{}},
+		{`{"net":[{"name":"eth0","rx":1},{"name":"wlan0","rx":2}]}`,
+			map[string]string{"net.eth0.name": `"eth0"`, "net.eth0.rx": "1",
+				"net.wlan0.name": `"wlan0"`, "net.wlan0.rx": "2"}},
+		{`{"disks":[{"path":"/","free":1},{"path":"/mnt/my disk","free":2}]}`,
+			map[string]string{"disks._.path": `"/"`, "disks._.free": "1",
+				"disks._mnt_my_disk.path": `"/mnt/my disk"`,
+				"disks._mnt_my_disk.free": "2"}},
+		{`{"a":[{"name":"x","v":1},{"name":"x","v":2},{"v":3}]}`,
+			map[string]string{"a.x.name": `"x"`, "a.x.v": "1",
+				"a.1.name": `"x"`, "a.1.v": "2", "a.2.v": "3"}},
+	}
+
+	for _, tt := range tests {
+		var tree interface{}
+		err := json.Unmarshal([]byte(tt.in), &tree)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		kv := make(map[string]string)
+		flatten("", tree, kv)
+		if len(kv) != len(tt.want) {
+			t.Errorf("flatten(%s) = %v, want %v", tt.in, kv, tt.want)
+			continue
+		}
+		for k, v := range tt.want {
+			if kv[k] != v {
+				t.Errorf("flatten(%s)[%s] = %q, want %q", tt.in, k, kv[k], v)
+			}
+		}
+	}
+}
+
+func TestGetKv(t *testing.T) {
+	ex := exportT{
+		Version: schemaVersion,
+		Loads:   [3]float64{0.25, 0, 1},
+		Mem:     exportMemT{Total: 1024, ThpMode: "madvise"},
+		Temps: []exportTempT{{Group: "cpu", Max: 51.5,
+			Readings: []float64{50, 51.5}}},
+	}
+
+	kv := getKv(ex)
+	want := map[string]string{
+		"version":              strconv.Itoa(schemaVersion),
+		"loads.0":              "0.25",
+		"mem.total":            "1024",
+		"mem.thp_mode":         `"madvise"`,
+		"temps.cpu.group":      `"cpu"`,
+		"temps.cpu.max":        "51.5",
+		"temps.cpu.readings.1": "51.5",
+	}
+	for k, v := range want {
+		if kv[k] != v {
+			t.Errorf("getKv()[%s] = %q, want %q", k, kv[k], v)
+		}
+	}
+
+	// omitted pointers don't leave keys behind
+	for _, k := range []string{"battery.level", "cpu.busy", "vpn.up"} {
+		if _, ok := kv[k]; ok {
+			t.Errorf("getKv() has %s", k)
+		}
+	}
+}
+
+func TestElemKey(t *testing.T) {
+	tests := []struct {
+		in   string
+		want string
+	}{
+		{`{"name":"eth0"}`, "eth0"},
+		{`{"iface":"wlp3s0","ssid":"home"}`, "wlp3s0"},
+		{`{"path":"/home"}`, "_home"},
+		{`{"group":"cpu1","label":"CPU"}`, "cpu1"},
+		{`{"pid":1234,"comm":"sh"}`, "1234"},
+		{`{"chip":"nvme","hwmon":"hwmon3","label":"Composite"}`,
+			"nvme_Composite"},
+		{`{"chip":"coretemp","label":"Core 0"}`, "coretemp_Core_0"},
+		{`{"name":"","path":"/"}`, "_"},
+		{`{"busy":1}`, ""},
+		{`1.5`, ""},
+	}
+
+	for _, tt := range tests {
+		var node interface{}
+		err := json.Unmarshal([]byte(tt.in), &node)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := elemKey(node); got != tt.want {
+			t.Errorf("elemKey(%s) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
@@ -2,16 +2,18 @@ module stts
 
 go 1.18
 
-require github.com/mdlayher/wifi v0.0.0-20220330172155-a44c70b6d3c8
+require (
+	github.com/mdlayher/genetlink v1.2.0
+	github.com/mdlayher/netlink v1.6.0
+	github.com/mdlayher/wifi v0.0.0-20220330172155-a44c70b6d3c8
+	golang.org/x/sys v0.0.0-20220307203707-22a9840ba4d7
+)
 
 require (
 	github.com/google/go-cmp v0.5.7 // indirect
 	github.com/josharian/native v1.0.0 // indirect
-	github.com/mdlayher/genetlink v1.2.0 // indirect
-	github.com/mdlayher/netlink v1.6.0 // indirect
 	github.com/mdlayher/socket v0.2.2 // indirect
 	golang.org/x/crypto v0.0.0-20220214200702-86341886e292 // indirect
 	golang.org/x/net v0.0.0-20220225172249-27dd8689420f // indirect
 	golang.org/x/sync v0.0.0-20210220032951-036812b2e83c // indirect
-	golang.org/x/sys v0.0.0-20220307203707-22a9840ba4d7 // indirect
 )
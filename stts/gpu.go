@@ -0,0 +1,160 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"regexp"
+	"sort"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type gpuDevT struct {
+	name   string
+	driver string
+	hwmon  string
+
+	fanFd      *os.File
+	powerFd    *os.File
+	busyFd     *os.File
+	vramUsedFd *os.File
+	vramTotal  int64
+}
+
+type gpuT struct {
+	name   string
+	driver string
+
+	// fan in RPM, power in W, busy in percent and vram in bytes
+	fan       int64
+	power     float64
+	busy      int64
+	vramUsed  int64
+	vramTotal int64
+}
+
+var nvmeDevRe = regexp.MustCompile(`^nvme[0-9]+$`)
+
+// detectGpus sets up a device for every amdgpu, nouveau and i915 hwmon; the
+// drm attributes like vram usage are only provided by amdgpu
+func detectGpus(vars *varsT) {
+	sort.Slice(vars.gpuHwmons, func(i, j int) bool {
+		return hwmonIndex(vars.gpuHwmons[i]) < hwmonIndex(vars.gpuHwmons[j])
+	})
+
+	for i, hwmon := range vars.gpuHwmons {
+		devDir := fp.Join(hwmon, "device")
+
+		gpu := gpuDevT{
+			name:   fmt.Sprintf("gpu%d", i),
+			driver: readSysString(fp.Join(hwmon, "name")),
+			hwmon:  hwmon,
+		}
+
+		cards, _ := fp.Glob(fp.Join(devDir, "drm/card[0-9]*"))
+		if len(cards) > 0 {
+			gpu.name = "gpu" + str.TrimPrefix(fp.Base(cards[0]), "card")
+		}
+
+		gpu.fanFd = openFirst(fp.Join(hwmon, "fan1_input"))
+		gpu.powerFd = openFirst(fp.Join(hwmon, "power1_input"),
+			fp.Join(hwmon, "power1_average"))
+		gpu.busyFd = openFirst(fp.Join(devDir, "gpu_busy_percent"))
+		gpu.vramUsedFd = openFirst(fp.Join(devDir, "mem_info_vram_used"))
+		gpu.vramTotal = int64(readSysFloat(
+			fp.Join(devDir, "mem_info_vram_total")))
+
+		vars.gpus = append(vars.gpus, gpu)
+	}
+}
+
+func getGpuInfo(st *sttsT, vars *varsT) {
+	if len(st.gpus) != len(vars.gpus) {
+		st.gpus = make([]gpuT, len(vars.gpus))
+	}
+
+	for i, dev := range vars.gpus {
+		gpu := &st.gpus[i]
+		gpu.name = dev.name
+		gpu.driver = dev.driver
+		gpu.vramTotal = dev.vramTotal
+
+		if dev.fanFd != nil {
+			gpu.fan, _ = readSysInt(dev.fanFd, vars)
+		}
+
+		if dev.powerFd != nil {
+			power, _ := readSysInt(dev.powerFd, vars)
+			gpu.power = float64(power) / sensorDiv("power")
+		}
+
+		if dev.busyFd != nil {
+			gpu.busy, _ = readSysInt(dev.busyFd, vars)
+		}
+
+		if dev.vramUsedFd != nil {
+			gpu.vramUsed, _ = readSysInt(dev.vramUsedFd, vars)
+		}
+	}
+}
+
+// addGpuTempGroups adds a temperature group per gpu, labelled just "gpu"
+// when there is only one
+func addGpuTempGroups(vars *varsT) {
+	for _, gpu := range vars.gpus {
+		label := gpu.name
+		if len(vars.gpus) == 1 {
+			label = "gpu"
+		}
+
+		addTempGroup(vars, gpu.name, label, "gpu",
+			openHwmon(gpu.hwmon, "temp.*_input"))
+	}
+}
+
+// addNvmeTempGroups adds a temperature group per nvme controller with only
+// its composite temperature, the other sensors are in the hwmon inventory
+func addNvmeTempGroups(vars *varsT) {
+	sort.Slice(vars.nvmeHwmons, func(i, j int) bool {
+		return hwmonIndex(vars.nvmeHwmons[i]) <
+			hwmonIndex(vars.nvmeHwmons[j])
+	})
+
+	for _, hwmon := range vars.nvmeHwmons {
+		files := getTempInputs(hwmon, "Composite")
+		if len(files) == 0 {
+			files = []string{fp.Join(hwmon, "temp1_input")}
+		}
+
+		name := getNvmeName(hwmon)
+		addTempGroup(vars, name, name, "nvme", openFiles(files))
+	}
+}
+
+// getNvmeName returns the controller name of an nvme hwmon; newer kernels
+// link the hwmon to the nvme device, older ones to the pci device
+func getNvmeName(hwmon string) string {
+	devDir, err := fp.EvalSymlinks(fp.Join(hwmon, "device"))
+	if err == nil && nvmeDevRe.MatchString(fp.Base(devDir)) {
+		return fp.Base(devDir)
+	}
+
+	ctrls, _ := fp.Glob(fp.Join(hwmon, "device/nvme/nvme[0-9]*"))
+	if len(ctrls) > 0 {
+		return fp.Base(ctrls[0])
+	}
+
+	return fp.Base(hwmon)
+}
+
+// openFirst opens the first of the files that exists, nil when none does
+func openFirst(files ...string) *os.File {
+	for _, file := range files {
+		fd, err := os.Open(file)
+		if err == nil {
+			return fd
+		}
+	}
+	return nil
+}
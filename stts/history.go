@@ -0,0 +1,409 @@
+package main
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"time"
+
+	str "strings"
+)
+
+// the history file is a ring buffer of fixed size slots after a header and
+// a table of metric keys; a slot is the unix time followed by a float64 per
+// key, NaN when the metric wasn't there at that time
+//
+//	0   magic "STTSHIST"
+//	8   version
+//	12  max keys, keys in use, slots, next slot, slots in use
+//	64  key table, max keys * histKeyLen bytes
+//	... slots, 8 + max keys * 8 bytes each
+const (
+	histMagic      = "STTSHIST"
+	histVersion    = 2
+	histHeaderSize = 64
+	histKeyLen     = 64
+)
+
+var errHistVersion = errors.New("unsupported history file version")
+
+type histT struct {
+	fd *os.File
+
+	maxKeys  uint32
+	keyCount uint32
+	slots    uint32
+	head     uint32
+	count    uint32
+
+	keys   []string
+	keyIdx map[string]int
+}
+
+type histSampleT struct {
+	time time.Time
+	vals []float64
+}
+
+func recordHistory(st *sttsT, vars *varsT) {
+	slots := int(vars.histRetention / vars.histInterval)
+	if slots < 1 {
+		slots = 1
+	}
+
+	getAllInfo(st, vars)
+	vals := getKvVals(st, vars)
+
+	var keys []string
+	for k := range vals {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	hist, err := openHistory(vars.histFile, uint32(slots), keys)
+	errExit(err)
+	defer hist.fd.Close()
+
+	tick := time.NewTicker(vars.histInterval)
+	defer tick.Stop()
+
+	for {
+		err = hist.add(time.Now(), vals)
+		errExit(err)
+		checkAlerts(st, vars)
+
+		<-tick.C
+		getAllInfo(st, vars)
+		vals = getKvVals(st, vars)
+	}
+}
+
+// openHistory opens a history file for recording; keys not in the file yet
+// are added while there is room, otherwise the file starts over; a file
+// that isn't empty and isn't a history file is never overwritten
+func openHistory(file string, slots uint32, keys []string) (*histT, error) {
+	fd, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE, 0644)
+	if err != nil {
+		return nil, err
+	}
+
+	info, err := fd.Stat()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	if info.Size() > 0 {
+		hist, err := readHistHeader(fd)
+		if err != nil && !errors.Is(err, errHistVersion) {
+			fd.Close()
+			return nil, fmt.Errorf("%s: %w", file, err)
+		}
+		if err == nil && hist.slots == slots && hist.addKeys(keys) {
+			return hist, hist.writeKeys()
+		}
+
+		fmt.Fprintf(os.Stderr, "history version, settings or metrics "+
+			"changed, starting over: %s\n", file)
+	}
+
+	hist := &histT{
+		fd:      fd,
+		maxKeys: uint32(len(keys) + len(keys)/2 + 16),
+		slots:   slots,
+		keyIdx:  make(map[string]int),
+	}
+	hist.addKeys(keys)
+
+	err = fd.Truncate(hist.dataOffset() + int64(slots)*hist.slotSize())
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	err = hist.writeHeader()
+	if err != nil {
+		fd.Close()
+		return nil, err
+	}
+
+	return hist, hist.writeKeys()
+}
+
+func readHistory(file string) (*histT, error) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+
+	hist, err := readHistHeader(fd)
+	if err != nil {
+		fd.Close()
+		return nil, fmt.Errorf("%s: %w", file, err)
+	}
+
+	return hist, nil
+}
+
+func readHistHeader(fd *os.File) (*histT, error) {
+	var header [histHeaderSize]byte
+	_, err := fd.ReadAt(header[:], 0)
+	if err == io.EOF {
+		return nil, errors.New("not a history file")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if string(header[:8]) != histMagic {
+		return nil, errors.New("not a history file")
+	}
+
+	le := binary.LittleEndian
+	if le.Uint32(header[8:]) != histVersion {
+		return nil, errHistVersion
+	}
+
+	hist := &histT{
+		fd:       fd,
+		maxKeys:  le.Uint32(header[12:]),
+		keyCount: le.Uint32(header[16:]),
+		slots:    le.Uint32(header[20:]),
+		head:     le.Uint32(header[24:]),
+		count:    le.Uint32(header[28:]),
+		keyIdx:   make(map[string]int),
+	}
+
+	table := make([]byte, hist.keyCount*histKeyLen)
+	_, err = fd.ReadAt(table, histHeaderSize)
+	if err != nil {
+		return nil, err
+	}
+
+	for i := 0; i < int(hist.keyCount); i++ {
+		key := table[i*histKeyLen : (i+1)*histKeyLen]
+		key = bytes.TrimRight(key, "\x00")
+		hist.keys = append(hist.keys, string(key))
+		hist.keyIdx[string(key)] = i
+	}
+
+	return hist, nil
+}
+
+// addKeys adds keys missing from the key table and returns false when they
+// don't fit
+func (hist *histT) addKeys(keys []string) bool {
+	for _, key := range keys {
+		if _, ok := hist.keyIdx[key]; ok || len(key) >= histKeyLen {
+			continue
+		}
+		if hist.keyCount >= hist.maxKeys {
+			return false
+		}
+
+		hist.keyIdx[key] = int(hist.keyCount)
+		hist.keys = append(hist.keys, key)
+		hist.keyCount++
+	}
+
+	return true
+}
+
+func (hist *histT) writeHeader() error {
+	buf := make([]byte, histHeaderSize)
+	copy(buf, histMagic)
+
+	le := binary.LittleEndian
+	le.PutUint32(buf[8:], histVersion)
+	le.PutUint32(buf[12:], hist.maxKeys)
+	le.PutUint32(buf[16:], hist.keyCount)
+	le.PutUint32(buf[20:], hist.slots)
+	le.PutUint32(buf[24:], hist.head)
+	le.PutUint32(buf[28:], hist.count)
+
+	_, err := hist.fd.WriteAt(buf, 0)
+	return err
+}
+
+func (hist *histT) writeKeys() error {
+	buf := make([]byte, hist.maxKeys*histKeyLen)
+	for i, key := range hist.keys {
+		copy(buf[i*histKeyLen:], key)
+	}
+
+	_, err := hist.fd.WriteAt(buf, histHeaderSize)
+	if err != nil {
+		return err
+	}
+
+	return hist.writeHeader()
+}
+
+func (hist *histT) add(t time.Time, vals map[string]float64) error {
+	buf := make([]byte, hist.slotSize())
+
+	le := binary.LittleEndian
+	le.PutUint64(buf, uint64(t.Unix()))
+	for i := uint32(0); i < hist.maxKeys; i++ {
+		le.PutUint64(buf[8+i*8:], math.Float64bits(math.NaN()))
+	}
+	for key, val := range vals {
+		// keys too long for the key table are never added
+		i, ok := hist.keyIdx[key]
+		if !ok {
+			continue
+		}
+		le.PutUint64(buf[8+i*8:], math.Float64bits(val))
+	}
+
+	_, err := hist.fd.WriteAt(buf, hist.slotOffset(hist.head))
+	if err != nil {
+		return err
+	}
+
+	hist.head = (hist.head + 1) % hist.slots
+	if hist.count < hist.slots {
+		hist.count++
+	}
+
+	return hist.writeHeader()
+}
+
+// samples returns the recorded samples since a time, oldest first
+func (hist *histT) samples(since time.Time) ([]histSampleT, error) {
+	var samples []histSampleT
+
+	start := uint32(0)
+	if hist.count == hist.slots {
+		start = hist.head
+	}
+
+	buf := make([]byte, hist.slotSize())
+	le := binary.LittleEndian
+
+	for i := uint32(0); i < hist.count; i++ {
+		slot := (start + i) % hist.slots
+
+		_, err := hist.fd.ReadAt(buf, hist.slotOffset(slot))
+		if err != nil && err != io.EOF {
+			return nil, err
+		}
+
+		t := time.Unix(int64(le.Uint64(buf)), 0)
+		if t.Before(since) {
+			continue
+		}
+
+		sample := histSampleT{time: t}
+		for k := uint32(0); k < hist.keyCount; k++ {
+			bits := le.Uint64(buf[8+k*8:])
+			sample.vals = append(sample.vals, math.Float64frombits(bits))
+		}
+		samples = append(samples, sample)
+	}
+
+	return samples, nil
+}
+
+func (hist *histT) dataOffset() int64 {
+	return histHeaderSize + int64(hist.maxKeys)*histKeyLen
+}
+
+func (hist *histT) slotSize() int64 {
+	return 8 + int64(hist.maxKeys)*8
+}
+
+func (hist *histT) slotOffset(slot uint32) int64 {
+	return hist.dataOffset() + int64(slot)*hist.slotSize()
+}
+
+// queryHistory prints min/avg/max or every value of the metrics matching a
+// glob pattern, e.g. temps.*.max, recorded within the last since
+func queryHistory(pattern string, since time.Duration, series bool,
+	vars *varsT) {
+
+	hist, err := readHistory(vars.histFile)
+	errExit(err)
+	defer hist.fd.Close()
+
+	var keys []string
+	for _, key := range hist.keys {
+		match, err := path.Match(pattern, key)
+		if err != nil {
+			errExit(fmt.Errorf("incorrect metric pattern: %s", pattern))
+		}
+		if match {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+
+	if len(keys) == 0 {
+		errExit(fmt.Errorf("no recorded metric matches: %s", pattern))
+	}
+
+	samples, err := hist.samples(time.Now().Add(-since))
+	errExit(err)
+
+	for _, key := range keys {
+		i := hist.keyIdx[key]
+
+		if series {
+			for _, s := range samples {
+				if math.IsNaN(s.vals[i]) {
+					continue
+				}
+				fmt.Printf("%s  %s %s\n", s.time.Format("2006-01-02 15:04:05"),
+					key, fmtHistVal(s.vals[i]))
+			}
+			continue
+		}
+
+		var min, max, sum float64
+		var n int
+		for _, s := range samples {
+			val := s.vals[i]
+			if math.IsNaN(val) {
+				continue
+			}
+			if n == 0 || val < min {
+				min = val
+			}
+			if n == 0 || val > max {
+				max = val
+			}
+			sum += val
+			n++
+		}
+
+		if n == 0 {
+			fmt.Printf("%-32s no samples\n", key)
+			continue
+		}
+		fmt.Printf("%-32s min %-12s avg %-12s max %-12s samples %d\n",
+			key, fmtHistVal(min), fmtHistVal(sum/float64(n)),
+			fmtHistVal(max), n)
+	}
+}
+
+// fmtHistVal formats a value rounded to 3 decimals, which is finer than any
+// metric is read with but keeps averages short
+func fmtHistVal(val float64) string {
+	return strconv.FormatFloat(math.Round(val*1000)/1000, 'f', -1, 64)
+}
+
+// parseRetention reads a duration, which can also be given in days, e.g. 7d
+func parseRetention(val string) (time.Duration, error) {
+	if str.HasSuffix(val, "d") {
+		days, err := strconv.Atoi(str.TrimSuffix(val, "d"))
+		return time.Duration(days) * 24 * time.Hour, err
+	}
+	return time.ParseDuration(val)
+}
@@ -0,0 +1,177 @@
+package main
+
+import (
+	"encoding/binary"
+	"math"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestParseRetention(t *testing.T) {
+	tests := []struct {
+		in      string
+		want    time.Duration
+		wantErr bool
+	}{
+		{"24h", 24 * time.Hour, false},
+		{"90m", 90 * time.Minute, false},
+		{"7d", 7 * 24 * time.Hour, false},
+		{"1d", 24 * time.Hour, false},
+		{"xd", 0, true},
+		{"7", 0, true},
+		{"", 0, true},
+	}
+
+	for _, tt := range tests {
+		got, err := parseRetention(tt.in)
+		if (err != nil) != tt.wantErr {
+			t.Errorf("parseRetention(%q) error = %v", tt.in, err)
+			continue
+		}
+		if !tt.wantErr && got != tt.want {
+			t.Errorf("parseRetention(%q) = %s, want %s", tt.in, got, tt.want)
+		}
+	}
+}
+
+func TestHistoryRing(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+	keys := []string{"mem.used", "net.eth0.rx_bytes"}
+
+	hist, err := openHistory(file, 3, keys)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// counters beyond 2^24 have to come back exactly
+	start := time.Unix(1700000000, 0)
+	for i := 0; i < 5; i++ {
+		vals := map[string]float64{"net.eth0.rx_bytes": 1<<40 + float64(i)}
+		if i != 3 {
+			vals["mem.used"] = float64(i)
+		}
+		err = hist.add(start.Add(time.Duration(i)*time.Second), vals)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	hist.fd.Close()
+
+	hist, err = readHistory(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer hist.fd.Close()
+
+	samples, err := hist.samples(time.Time{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(samples) != 3 {
+		t.Fatalf("got %d samples, want 3", len(samples))
+	}
+
+	used, rx := hist.keyIdx["mem.used"], hist.keyIdx["net.eth0.rx_bytes"]
+	for n, s := range samples {
+		i := n + 2
+		if !s.time.Equal(start.Add(time.Duration(i) * time.Second)) {
+			t.Errorf("sample %d at %s", n, s.time)
+		}
+		if s.vals[rx] != 1<<40+float64(i) {
+			t.Errorf("sample %d rx = %f, want %f", n, s.vals[rx],
+				1<<40+float64(i))
+		}
+		if i == 3 && !math.IsNaN(s.vals[used]) {
+			t.Errorf("sample %d mem.used = %f, want NaN", n, s.vals[used])
+		}
+		if i != 3 && s.vals[used] != float64(i) {
+			t.Errorf("sample %d mem.used = %f, want %d", n, s.vals[used], i)
+		}
+	}
+
+	samples, _ = hist.samples(start.Add(4 * time.Second))
+	if len(samples) != 1 {
+		t.Errorf("got %d samples since the last one, want 1", len(samples))
+	}
+}
+
+func TestOpenHistoryKeys(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "history")
+
+	hist, err := openHistory(file, 4, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.add(time.Unix(1, 0), map[string]float64{"a": 1})
+	hist.fd.Close()
+
+	// new keys are added to the existing file and samples are kept
+	hist, err = openHistory(file, 4, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hist.count != 1 || hist.keyCount != 2 {
+		t.Errorf("count %d keys %d, want 1 and 2", hist.count, hist.keyCount)
+	}
+	hist.fd.Close()
+
+	// other settings start over
+	hist, err = openHistory(file, 8, []string{"a", "b"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hist.count != 0 || hist.slots != 8 {
+		t.Errorf("count %d slots %d, want 0 and 8", hist.count, hist.slots)
+	}
+	hist.fd.Close()
+
+	// as does a history file of another version
+	fd, err := os.OpenFile(file, os.O_RDWR, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var version [4]byte
+	binary.LittleEndian.PutUint32(version[:], histVersion-1)
+	fd.WriteAt(version[:], 8)
+	fd.Close()
+
+	hist, err = openHistory(file, 8, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.fd.Close()
+}
+
+func TestOpenHistoryForeignFile(t *testing.T) {
+	dir := t.TempDir()
+
+	for _, content := range []string{"short", "some file that is longer " +
+		"than the header of a history file and isn't one at all\n"} {
+		file := filepath.Join(dir, "notes")
+		err := os.WriteFile(file, []byte(content), 0644)
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		_, err = openHistory(file, 4, []string{"a"})
+		if err == nil {
+			t.Errorf("openHistory accepted a file of %d bytes", len(content))
+		}
+
+		got, _ := os.ReadFile(file)
+		if string(got) != content {
+			t.Errorf("openHistory changed the file to %q", got)
+		}
+	}
+
+	// an empty file, e.g. created ahead with the right owner, is used
+	file := filepath.Join(dir, "empty")
+	os.WriteFile(file, nil, 0644)
+	hist, err := openHistory(file, 4, []string{"a"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	hist.fd.Close()
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"fmt"
 	"io/ioutil"
 	"os"
 	"strconv"
@@ -9,87 +10,119 @@ import (
 	str "strings"
 )
 
-func readCpuTemps(st *sttsT, vars *varsT) {
-	st.cpu1Temp = ""
-	st.cpu2Temp = ""
-	if vars.debug {
-		st.cpu1Temps = []string{}
-		st.cpu2Temps = []string{}
-	}
+type tempGroupT struct {
+	name  string
+	label string
+	agg   string
+	fds   []*os.File
+}
 
-	for _, fd := range vars.cpu1TempFds {
-		temp := readTemp(fd, vars)
-		if temp > st.cpu1Temp {
-			st.cpu1Temp = temp
-		}
-		if vars.debug {
-			st.cpu1Temps = append(st.cpu1Temps, temp)
-		}
+type tempT struct {
+	name  string
+	label string
+	agg   string
+
+	// aggregated and single readings in millidegrees Celsius
+	temp  int
+	temps []int
+}
+
+func readTemps(st *sttsT, vars *varsT) {
+	if len(st.temps) != len(vars.tempGroups) {
+		st.temps = make([]tempT, len(vars.tempGroups))
 	}
 
-	for _, fd := range vars.cpu2TempFds {
-		temp := readTemp(fd, vars)
-		if temp > st.cpu2Temp {
-			st.cpu2Temp = temp
-		}
-		if vars.debug {
-			st.cpu2Temps = append(st.cpu2Temps, temp)
+	for i, group := range vars.tempGroups {
+		t := &st.temps[i]
+		t.name = group.name
+		t.label = group.label
+		t.agg = group.agg
+
+		t.temps = t.temps[:0]
+		for _, fd := range group.fds {
+			temp, err := readTemp(fd, vars)
+			if err != nil {
+				continue
+			}
+			t.temps = append(t.temps, temp)
 		}
+
+		t.temp = aggTemps(t.temps, group.agg)
 	}
 }
 
-func readDriveTemps(st *sttsT, vars *varsT) {
-	st.driveTemp = ""
-	if vars.debug {
-		st.driveTemps = []string{}
+func readTemp(fd *os.File, vars *varsT) (int, error) {
+	temp, err := readSysInt(fd, vars)
+	return int(temp), err
+}
+
+// aggTemps returns the average for "avg" and the maximum otherwise, as the
+// per-sensor "all" mode still needs a single value to check thresholds
+func aggTemps(temps []int, agg string) int {
+	if len(temps) == 0 {
+		return 0
 	}
-	for _, fd := range vars.driveTempFds {
-		temp := readTemp(fd, vars)
-		if temp > st.driveTemp {
-			st.driveTemp = temp
-		}
-		if vars.debug {
-			st.driveTemps = append(st.driveTemps, temp)
+
+	max, sum := temps[0], 0
+	for _, t := range temps {
+		if t > max {
+			max = t
 		}
+		sum += t
+	}
+
+	if agg == "avg" {
+		return sum / len(temps)
 	}
+	return max
 }
 
-func readMoboTemps(st *sttsT, vars *varsT) {
-	st.moboTemp = ""
-	if vars.debug {
-		st.moboTemps = []string{}
+func addTempGroup(vars *varsT, name, label, confKey string, fds []*os.File) {
+	if len(fds) == 0 {
+		return
 	}
 
-	for _, fd := range vars.moboTempFds {
-		temp := readTemp(fd, vars)
-		if temp > st.moboTemp {
-			st.moboTemp = temp
-		}
-		if vars.debug {
-			st.moboTemps = append(st.moboTemps, temp)
-		}
+	agg, ok := vars.tempAgg[confKey]
+	if !ok {
+		agg = vars.tempAggDefault
 	}
-}
 
-func readTemp(fd *os.File, vars *varsT) string {
-	tempBin := make([]byte, 2)
-	_, err := fd.Read(tempBin)
+	vars.tempGroups = append(vars.tempGroups, tempGroupT{
+		name:  name,
+		label: label,
+		agg:   agg,
+		fds:   fds,
+	})
+}
 
-	// skip for benchmarking as this poses a large i/o bottleneck
-	if !vars.bench {
-		fd.Seek(0, 0)
+// tempVal converts millidegrees Celsius to the configured display unit
+func tempVal(milli int, vars *varsT) float64 {
+	c := float64(milli) / 1000
+	if vars.tempUnit == "F" {
+		return c*9/5 + 32
 	}
+	return c
+}
 
-	if err != nil {
-		return "00"
+func fmtTemp(milli int, vars *varsT) string {
+	return fmt.Sprintf("%.*f°%s", vars.tempDecimals, tempVal(milli, vars),
+		vars.tempUnit)
+}
+
+func fmtTempGroup(t tempT, vars *varsT) string {
+	if len(t.temps) == 0 {
+		return "n/a"
 	}
 
-	temp := string(tempBin[:])
-	if temp == "10" || temp == "11" || len(temp) == 1 {
-		temp = "99"
+	if t.agg != "all" {
+		return fmtTemp(t.temp, vars)
 	}
 
-	return temp
+	var temps []string
+	for _, temp := range t.temps {
+		temps = append(temps, fmtTemp(temp, vars))
+	}
+	return str.Join(temps, " ")
 }
 
 func hwmonDetect(vars *varsT) {
@@ -119,6 +152,10 @@ func hwmonDetect(vars *varsT) {
 		case "drivetemp":
 			vars.driveTempHwmons = append(vars.driveTempHwmons,
 				hwmonName)
+		case "amdgpu", "nouveau", "i915":
+			vars.gpuHwmons = append(vars.gpuHwmons, hwmonName)
+		case "nvme":
+			vars.nvmeHwmons = append(vars.nvmeHwmons, hwmonName)
 		case "acpitz":
 			vars.moboTempHwmons = append(vars.moboTempHwmons,
 				hwmonName)
@@ -0,0 +1,200 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"os"
+	"os/exec"
+	"strconv"
+	"time"
+
+	str "strings"
+)
+
+type i3blockT struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance,omitempty"`
+	FullText string `json:"full_text"`
+	Color    string `json:"color,omitempty"`
+	Urgent   bool   `json:"urgent,omitempty"`
+}
+
+type i3clickT struct {
+	Name     string `json:"name"`
+	Instance string `json:"instance"`
+	Button   int    `json:"button"`
+}
+
+func printI3bar(st *sttsT, vars *varsT) {
+	fmt.Printf("{\"version\":1,\"click_events\":true}\n[\n")
+
+	clicks := make(chan i3clickT)
+	go readI3Clicks(clicks)
+
+	tick := time.NewTicker(5 * time.Second)
+	defer tick.Stop()
+
+	for {
+		getAllInfo(st, vars)
+		checkAlerts(st, vars)
+
+		out, err := json.Marshal(getI3Blocks(st, vars))
+		errExit(err)
+		fmt.Printf("%s,\n", out)
+
+		// a click refreshes the bar right away, so the result of a
+		// click command shows up without waiting for the next tick
+		select {
+		case <-tick.C:
+		case click := <-clicks:
+			runClickCmd(click, vars)
+		}
+	}
+}
+
+func getI3Blocks(st *sttsT, vars *varsT) []i3blockT {
+	var blocks []i3blockT
+
+	for i, info := range st.addInfo {
+		blocks = append(blocks, i3blockT{
+			Name:     "add_info",
+			Instance: strconv.Itoa(i),
+			FullText: info,
+		})
+	}
+
+	blocks = append(blocks, newI3block("load", "", fmtLoad(st),
+		vars.limits.load.level(st.loads[0]), vars))
+
+	if vars.has.cpuUsage {
+		blocks = append(blocks, newI3block("cpu", "", fmtCpu(st),
+			levelOk, vars))
+	}
+
+	var memPerc float64
+	if st.mem.total > 0 {
+		memPerc = float64(st.mem.used) / float64(st.mem.total) * 100
+	}
+	blocks = append(blocks, newI3block("mem", "", fmtMem(st),
+		vars.limits.mem.level(memPerc), vars))
+
+	if vars.diskConf {
+		for _, d := range st.disks {
+			blocks = append(blocks, newI3block("df", d.path,
+				fmtDiskMount(d), d.limit.level(d.freeMB()), vars))
+		}
+	} else {
+		blocks = append(blocks, newI3block("df", "/", fmtDisk(st),
+			vars.limits.disk.level(st.rootDiskFree), vars))
+	}
+
+	for _, io := range st.diskIo {
+		if elInSlice(vars.diskIoDevs, io.name) {
+			blocks = append(blocks, newI3block("disk_io", io.name,
+				fmtDiskIo(io), levelOk, vars))
+		}
+	}
+
+	for _, t := range getTempSegs(st, vars) {
+		blocks = append(blocks, newI3block("temp", t.group, t.text,
+			vars.limits.temp.level(t.temp), vars))
+	}
+
+	for _, n := range st.net {
+		if netShown(n, vars) {
+			blocks = append(blocks, newI3block("net", n.name,
+				fmtNet(n), levelOk, vars))
+		}
+	}
+
+	for _, w := range st.wifis {
+		level := levelOk
+		if w.info != nil {
+			signal := float64(w.info.Signal)
+			level = vars.limits.wifi.level(signal)
+		}
+		blocks = append(blocks, newI3block("wifi", w.iface,
+			fmtWifi(w), level, vars))
+	}
+
+	if vars.show.vpn {
+		level := levelOk
+		if !st.vpn.up {
+			level = levelCrit
+		}
+		blocks = append(blocks, newI3block("vpn", st.vpn.iface,
+			fmtVpn(st), level, vars))
+	}
+
+	if vars.show.pressure {
+		for _, seg := range vars.pressureSegs {
+			blocks = append(blocks, newI3block("pressure", seg,
+				fmtPressureSeg(seg, st), levelOk, vars))
+		}
+	}
+
+	if vars.has.bat {
+		batLevel, _ := strconv.ParseFloat(st.batLevel, 64)
+		blocks = append(blocks, newI3block("bat", "", fmtBat(st),
+			vars.limits.bat.level(batLevel), vars))
+	}
+
+	return blocks
+}
+
+func newI3block(name, instance, text string, level int, vars *varsT) i3blockT {
+	block := i3blockT{Name: name, Instance: instance, FullText: text}
+
+	switch level {
+	case levelWarn:
+		block.Color = vars.colorWarn
+	case levelCrit:
+		block.Color = vars.colorCrit
+		block.Urgent = true
+	}
+
+	return block
+}
+
+// readI3Clicks parses the endless json array sent by i3bar/swaybar on stdin;
+// every click event is on its own line, optionally prefixed with a comma
+func readI3Clicks(clicks chan<- i3clickT) {
+	input := bufio.NewScanner(os.Stdin)
+	for input.Scan() {
+		line := str.TrimSpace(input.Text())
+		line = str.TrimLeft(line, "[,")
+		if line == "" {
+			continue
+		}
+
+		var click i3clickT
+		err := json.Unmarshal([]byte(line), &click)
+		if err != nil {
+			continue
+		}
+
+		clicks <- click
+	}
+}
+
+func runClickCmd(click i3clickT, vars *varsT) {
+	cmdStr, ok := vars.clickCmds[click.Name]
+	if !ok {
+		return
+	}
+
+	cmd := exec.Command("sh", "-c", cmdStr)
+	cmd.Env = append(os.Environ(),
+		"BLOCK_NAME="+click.Name,
+		"BLOCK_INSTANCE="+click.Instance,
+		"BLOCK_BUTTON="+strconv.Itoa(click.Button))
+
+	err := cmd.Start()
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "click command failed: %s\n", err)
+		return
+	}
+
+	go cmd.Wait()
+}
@@ -0,0 +1,63 @@
+package main
+
+const (
+	levelOk = iota
+	levelWarn
+	levelCrit
+)
+
+type limitsT struct {
+	load thresholdT
+	mem  thresholdT
+	disk thresholdT
+	temp thresholdT
+	wifi thresholdT
+	bat  thresholdT
+}
+
+type thresholdT struct {
+	warn    float64
+	crit    float64
+	hasWarn bool
+	hasCrit bool
+
+	// lower values are worse, e.g. battery level or free disk space
+	low bool
+}
+
+func limitsInit() limitsT {
+	var limits limitsT
+
+	limits.disk.low = true
+	limits.wifi.low = true
+	limits.bat.low = true
+
+	return limits
+}
+
+func (t *thresholdT) setWarn(val float64) {
+	t.warn = val
+	t.hasWarn = true
+}
+
+func (t *thresholdT) setCrit(val float64) {
+	t.crit = val
+	t.hasCrit = true
+}
+
+func (t thresholdT) level(val float64) int {
+	if t.hasCrit && t.exceeds(val, t.crit) {
+		return levelCrit
+	}
+	if t.hasWarn && t.exceeds(val, t.warn) {
+		return levelWarn
+	}
+	return levelOk
+}
+
+func (t thresholdT) exceeds(val, limit float64) bool {
+	if t.low {
+		return val <= limit
+	}
+	return val >= limit
+}
@@ -2,6 +2,7 @@ package main
 
 import (
 	"flag"
+	"fmt"
 	"os"
 	"regexp"
 	"time"
@@ -15,28 +16,37 @@ type sttsT struct {
 	uptime       time.Duration
 	loads        [3]float64
 	procs        int
+	cpu          cpuT
 	mem          memT
 	rootDiskFree float64
+	disks        []diskT
+	diskIo       []diskIoT
 
-	cpu1Temp  string
-	cpu2Temp  string
-	driveTemp string
-	moboTemp  string
+	temps   []tempT
+	sensors []sensorT
+	gpus    []gpuT
 
-	cpu1Temps  []string
-	cpu2Temps  []string
-	driveTemps []string
-	moboTemps  []string
+	net []netT
 
-	wifiBss  *wifi.BSS
-	wifiInfo *wifi.StationInfo
+	wifis []wifiT
 
+	bats        []batT
 	batLevel    string
 	batTimeLeft string
+	batMinLeft  int
+	acOnline    bool
+
+	vpn vpnT
+
+	pressure pressureT
 
 	addInfo []string
 
 	ps []processT
+
+	// only filled by -cgroup and -sockets for the export
+	cgroups []cgroupT
+	socks   []procSocksT
 }
 
 type memT struct {
@@ -48,6 +58,19 @@ type memT struct {
 	cache  int
 	avail  int
 	huge   int
+
+	swapTotal  int
+	swapFree   int
+	swapUsed   int
+	swapCached int
+
+	dirty     int
+	writeback int
+
+	// anonymous memory backed by transparent hugepages
+	anonHuge int
+
+	zram []zramT
 }
 
 type processT struct {
@@ -55,6 +78,12 @@ type processT struct {
 	bin  string
 	args string
 	pwd  string
+	uid  int
+
+	// cpu usage in percent of one core and bytes read and written per
+	// second between two samples
+	cpu    float64
+	ioRate float64
 
 	readBytes  int
 	writeBytes int
@@ -132,62 +161,152 @@ type varsT struct {
 	login bool
 	debug bool
 
-	has  hasT
-	show showT
+	// output format for machine readers: "", "json" or "kv"
+	format string
+
+	top topT
+
+	treeRoot    int
+	treeSession int
+
+	tempUnit       string
+	tempDecimals   int
+	tempAggDefault string
+	tempAgg        map[string]string
+
+	has    hasT
+	show   showT
+	limits limitsT
+
+	colorWarn string
+	colorCrit string
+	clickCmds map[string]string
 
 	meminfoFd *os.File
+	zramDevs  []zramDevT
+	thpMode   string
+
+	diskMounts []diskMountT
+	diskConf   bool
+
+	diskstatsFd *os.File
+	diskIoTicks []diskIoTicksT
+	diskIoTime  time.Time
+	diskIoDevs  []string
+
+	procStatFd   *os.File
+	cpuFreqFds   []*os.File
+	cpuFreqCpus  []string
+	cpuTicks     []cpuTicksT
+	cpuTicksTime time.Time
+
+	tempGroups    []tempGroupT
+	tempGroupDefs []tempGroupDefT
+	tempMaps      []tempMapT
 
 	cpu1TempHwmon string
 	cpu2TempHwmon string
-	cpu1TempFds   []*os.File
-	cpu2TempFds   []*os.File
 
 	driveTempHwmons []string
-	driveTempFds    []*os.File
 
 	moboTempHwmons []string
 	i2cMoboTemps   []string
-	moboTempFds    []*os.File
+
+	gpuHwmons  []string
+	nvmeHwmons []string
+	gpus       []gpuDevT
+
+	hwmonChips []hwmonChipT
 
 	miscHwmonNames []string
 	miscI2cNames   []string
 
-	wifiClient *wifi.Client
-	wifiIface  *wifi.Interface
+	netIfaceNames []string
+	netIfaces     []netIfaceT
+	netTime       time.Time
 
-	batCapacityFd   *os.File
-	batEnergyFd     *os.File
-	batEnergyFullFd *os.File
-	batPowerFd      *os.File
-	batStatusFd     *os.File
+	wifiClient     *wifi.Client
+	nl80211        *nl80211T
+	wifiIfaces     []*wifi.Interface
+	wifiIfaceNames []string
+	wifiScan       bool
 
-	addInfoFd []*os.File
+	bats        []batDevT
+	acOnlineFds []*os.File
+
+	// fds are nil until the file exists
+	addInfoFd    []*os.File
+	addInfoFiles []string
 
 	vpnRoute   string
 	vpnPidFile string
+	vpnIface   string
+	vpnRouteFd *os.File
+
+	// tunnel interface of the previous sample
+	vpnIfacePrev string
+	vpnRxPrev    uint64
+	vpnTxPrev    uint64
+	vpnTime      time.Time
+
+	psiFds          [3]*os.File
+	vmstatFd        *os.File
+	vmstatPrev      vmstatT
+	vmstatTime      time.Time
+	coreThrottleFds []*os.File
+	pkgThrottleFds  []*os.File
+	pressureSegs    []string
+
+	histFile      string
+	histInterval  time.Duration
+	histRetention time.Duration
+
+	alerts      []alertRuleT
+	alertStates map[string]*alertStateT
+	alertCmd    string
+	alertNotify string
+	alertFile   string
+
+	collectHosts    []string
+	collectInterval time.Duration
+	collectStale    time.Duration
 }
 
 type showT struct {
+	cpuUsage  bool
+	diskIo    bool
+	hwmon     bool
 	cpuTemp   bool
 	moboTemp  bool
 	driveTemp bool
+	gpu       bool
+	nvmeTemp  bool
+	net       bool
 	wifi      bool
 	bat       bool
 	vpn       bool
+	pressure  bool
 }
 
 type hasT struct {
-	cpu1Temp  bool
-	cpu2Temp  bool
-	moboTemp  bool
-	driveTemp bool
-	wifi      bool
-	bat       bool
+	cpuUsage bool
+	diskIo   bool
+	hwmon    bool
+	gpu      bool
+	net      bool
+	wifi     bool
+	bat      bool
+	psi      bool
 }
 
 func main() {
 	var oneLine, oneLineOnce, bench, files, env, login, debug bool
-	var configFile string
+	var jsonOut, kvOut, i3bar, top, tui, tree, record, series, cgroup bool
+	var sockets, collect bool
+	var configFile, serveAddr, topSort, topUser, topMatch string
+	var query string
+	var since time.Duration
+	var topLimit, treeRoot, treeSession, procPid int
 
 	flag.StringVar(&configFile, "c", "/etc/stts.conf", "path to a config")
 	flag.BoolVar(&oneLine, "o", false, "print info in one line repeatedly")
@@ -197,6 +316,27 @@ func main() {
 	flag.BoolVar(&env, "e", false, "show env vars")
 	flag.BoolVar(&login, "l", false, "show login shell processes")
 	flag.BoolVar(&debug, "d", false, "add debugging info")
+	flag.BoolVar(&jsonOut, "json", false, "print info as json")
+	flag.BoolVar(&kvOut, "kv", false, "print info as key=value lines")
+	flag.BoolVar(&i3bar, "i3bar", false, "stream info in i3bar protocol")
+	flag.StringVar(&serveAddr, "serve", "", "serve metrics and json on address")
+	flag.BoolVar(&collect, "collect", false, "poll collect_host agents")
+	flag.BoolVar(&top, "top", false, "show processes sorted by usage")
+	flag.BoolVar(&tui, "tui", false, "show an interactive dashboard")
+	flag.BoolVar(&tree, "tree", false, "show the process tree")
+	flag.BoolVar(&cgroup, "cgroup", false, "show resource usage per cgroup")
+	flag.IntVar(&procPid, "p", 0, "show details of a process")
+	flag.BoolVar(&sockets, "sockets", false, "show sockets per process")
+	flag.IntVar(&treeRoot, "root", 0, "show the process tree from a pid")
+	flag.IntVar(&treeSession, "session", 0, "show the process tree of a session")
+	flag.StringVar(&topSort, "sort", "cpu", "sort processes by cpu|mem|io|fds")
+	flag.StringVar(&topUser, "user", "", "show only processes of a user")
+	flag.StringVar(&topMatch, "match", "", "show only processes matching regex")
+	flag.IntVar(&topLimit, "n", 0, "show at most n processes")
+	flag.BoolVar(&record, "record", false, "record samples to the history file")
+	flag.StringVar(&query, "query", "", "show recorded metrics matching a glob")
+	flag.DurationVar(&since, "since", time.Hour, "time window of -query")
+	flag.BoolVar(&series, "series", false, "show every value with -query")
 
 	flag.Parse()
 
@@ -209,13 +349,76 @@ func main() {
 	vars.login = login
 	vars.debug = debug
 	vars.show = showInit()
+	vars.limits = limitsInit()
+	vars.colorWarn = "#ffff00"
+	vars.colorCrit = "#ff0000"
+	vars.clickCmds = make(map[string]string)
+	vars.tempUnit = "C"
+	vars.tempAggDefault = "max"
+	vars.tempAgg = make(map[string]string)
+	vars.histFile = "/var/lib/stts/history"
+	vars.histInterval = 10 * time.Second
+	vars.histRetention = 24 * time.Hour
+	vars.collectInterval = 5 * time.Second
+	vars.collectStale = time.Minute
+
+	switch {
+	case jsonOut:
+		vars.format = "json"
+	case kvOut:
+		vars.format = "kv"
+	}
+	vars.top.sort = topSort
+	vars.top.user = topUser
+	vars.top.limit = topLimit
+	vars.treeRoot = treeRoot
+	vars.treeSession = treeSession
+	if topMatch != "" {
+		re, err := regexp.Compile(topMatch)
+		errExit(err)
+		vars.top.match = re
+	}
+
+	switch topSort {
+	case "cpu", "mem", "io", "fds":
+	default:
+		errExit(fmt.Errorf("incorrect sort key: %s", topSort))
+	}
 
 	err := parseConfig(configFile, &vars)
 	errExit(err)
 
+	if query != "" {
+		queryHistory(query, since, series, &vars)
+		return
+	}
+
+	if collect {
+		runCollector(oneLineOnce, &vars)
+		return
+	}
+
 	getVars(&vars)
 
 	switch {
+	case record:
+		recordHistory(&st, &vars)
+	case serveAddr != "":
+		serveMetrics(serveAddr, &st, &vars)
+	case procPid > 0:
+		printProcDetail(procPid, &vars)
+	case tree || treeRoot > 0 || treeSession > 0:
+		printTree(&st, &vars)
+	case cgroup:
+		printCgroups(&st, &vars)
+	case sockets:
+		printSockets(&st, &vars)
+	case tui:
+		runTui(&st, &vars)
+	case top:
+		printTop(&st, &vars)
+	case i3bar:
+		printI3bar(&st, &vars)
 	case oneLine:
 		printOneLine(&st, &vars)
 	case oneLineOnce:
@@ -239,12 +442,17 @@ func main() {
 
 func getAllInfo(st *sttsT, vars *varsT) {
 	getSysinfo(st, vars)
+	getCpuInfo(st, vars)
 	getDiskInfo(st, vars)
-	readCpuTemps(st, vars)
-	readDriveTemps(st, vars)
-	readMoboTemps(st, vars)
+	getDiskIoInfo(st, vars)
+	readTemps(st, vars)
+	getSensorInfo(st, vars)
+	getGpuInfo(st, vars)
+	getNetInfo(st, vars)
 	getWifiInfo(st, vars)
 	getBatInfo(st, vars)
+	getVpnInfo(st, vars)
+	getPressureInfo(st, vars)
 	readAddInfo(st, vars)
 }
 
@@ -253,9 +461,34 @@ func getVars(vars *varsT) {
 	vars.meminfoFd, err = os.Open("/proc/meminfo")
 	errExit(err)
 
+	detectZram(vars)
+	vars.thpMode = getThpMode()
+
+	if vars.show.cpuUsage {
+		detectCpu(vars)
+	}
+
+	detectDisks(vars)
+
+	if vars.show.diskIo {
+		detectDiskIo(vars)
+	}
+
 	hwmonDetect(vars)
 	i2cDetect(vars)
 
+	if vars.show.hwmon {
+		detectSensors(vars)
+	}
+
+	if vars.show.gpu {
+		detectGpus(vars)
+	}
+
+	if vars.show.net {
+		detectNet(vars)
+	}
+
 	if vars.show.wifi {
 		detectWlan(vars)
 	}
@@ -264,51 +497,88 @@ func getVars(vars *varsT) {
 		detectBat(vars)
 	}
 
+	if vars.show.vpn {
+		detectVpn(vars)
+	}
+
+	if vars.show.pressure {
+		detectPressure(vars)
+	}
+
 	if vars.show.cpuTemp {
-		vars.cpu1TempFds = openHwmon(vars.cpu1TempHwmon, "temp.*input")
-		vars.cpu2TempFds = openHwmon(vars.cpu2TempHwmon, "temp.*input")
+		cpu1Fds := openHwmon(vars.cpu1TempHwmon, "temp.*input")
+		cpu2Fds := openHwmon(vars.cpu2TempHwmon, "temp.*input")
+		if len(cpu2Fds) == 0 {
+			addTempGroup(vars, "cpu1", "cpu", "cpu", cpu1Fds)
+		} else {
+			addTempGroup(vars, "cpu1", "cpu1", "cpu", cpu1Fds)
+			addTempGroup(vars, "cpu2", "cpu2", "cpu", cpu2Fds)
+		}
 	}
 
 	if vars.show.moboTemp {
+		var moboFds []*os.File
 		for _, hwmon := range vars.moboTempHwmons {
-			vars.moboTempFds = append(vars.moboTempFds,
+			moboFds = append(moboFds,
 				openHwmon(hwmon, "temp.*_input")...)
 		}
 
-		vars.moboTempFds = append(vars.moboTempFds,
-			openFiles(vars.i2cMoboTemps)...)
+		moboFds = append(moboFds, openFiles(vars.i2cMoboTemps)...)
+		addTempGroup(vars, "mobo", "mb", "mobo", moboFds)
 	}
 
 	if vars.show.driveTemp {
+		var driveFds []*os.File
 		for _, hwmon := range vars.driveTempHwmons {
-			vars.driveTempFds = append(vars.driveTempFds,
+			driveFds = append(driveFds,
 				openHwmon(hwmon, "temp.*_input")...)
 		}
+		addTempGroup(vars, "drive", "d", "drive", driveFds)
+	}
+
+	if vars.show.gpu {
+		addGpuTempGroups(vars)
+	}
+
+	if vars.show.nvmeTemp {
+		addNvmeTempGroups(vars)
+	}
+
+	applyTempMaps(vars)
+
+	if vars.procStatFd != nil {
+		vars.has.cpuUsage = true
 	}
 
-	if len(vars.cpu1TempFds) > 0 {
-		vars.has.cpu1Temp = true
+	if vars.diskstatsFd != nil {
+		vars.has.diskIo = true
 	}
 
-	if len(vars.cpu2TempFds) > 0 {
-		vars.has.cpu2Temp = true
+	if len(vars.hwmonChips) > 0 {
+		vars.has.hwmon = true
 	}
 
-	if len(vars.driveTempFds) > 0 {
-		vars.has.driveTemp = true
+	if len(vars.gpus) > 0 {
+		vars.has.gpu = true
 	}
 
-	if len(vars.moboTempFds) > 0 {
-		vars.has.moboTemp = true
+	if len(vars.netIfaces) > 0 {
+		vars.has.net = true
 	}
 
-	if vars.wifiIface != nil {
+	if len(vars.wifiIfaces) > 0 {
 		vars.has.wifi = true
 	}
 
-	if vars.batCapacityFd != nil {
+	if len(vars.bats) > 0 {
 		vars.has.bat = true
 	}
+
+	for _, fd := range vars.psiFds {
+		if fd != nil {
+			vars.has.psi = true
+		}
+	}
 }
 
 func openHwmon(hwmonDir string, ex string) []*os.File {
@@ -356,56 +626,80 @@ func openFiles(files []string) []*os.File {
 
 func closeFiles(vars *varsT) {
 	vars.meminfoFd.Close()
+	closeZram(vars)
 
-	for _, fd := range vars.cpu1TempFds {
-		fd.Close()
+	if vars.procStatFd != nil {
+		vars.procStatFd.Close()
 	}
 
-	for _, fd := range vars.cpu2TempFds {
+	for _, fd := range vars.cpuFreqFds {
 		fd.Close()
 	}
 
-	for _, fd := range vars.driveTempFds {
-		fd.Close()
+	if vars.diskstatsFd != nil {
+		vars.diskstatsFd.Close()
 	}
 
-	for _, fd := range vars.moboTempFds {
-		fd.Close()
+	for _, group := range vars.tempGroups {
+		for _, fd := range group.fds {
+			fd.Close()
+		}
 	}
 
-	if vars.wifiClient != nil {
-		vars.wifiClient.Close()
+	for _, chip := range vars.hwmonChips {
+		for _, sensor := range chip.sensors {
+			sensor.fd.Close()
+		}
 	}
 
-	if vars.batCapacityFd != nil {
-		vars.batCapacityFd.Close()
+	for _, gpu := range vars.gpus {
+		for _, fd := range []*os.File{gpu.fanFd, gpu.powerFd, gpu.busyFd,
+			gpu.vramUsedFd} {
+			if fd != nil {
+				fd.Close()
+			}
+		}
 	}
 
-	if vars.batEnergyFd != nil {
-		vars.batEnergyFd.Close()
+	for _, iface := range vars.netIfaces {
+		iface.rxFd.Close()
+		iface.txFd.Close()
+		if iface.stateFd != nil {
+			iface.stateFd.Close()
+		}
 	}
 
-	if vars.batEnergyFullFd != nil {
-		vars.batEnergyFullFd.Close()
+	if vars.wifiClient != nil {
+		vars.wifiClient.Close()
 	}
-
-	if vars.batPowerFd != nil {
-		vars.batPowerFd.Close()
+	if vars.nl80211 != nil {
+		vars.nl80211.conn.Close()
 	}
 
-	if vars.batStatusFd != nil {
-		vars.batStatusFd.Close()
+	closeBat(vars)
+
+	if vars.vpnRouteFd != nil {
+		vars.vpnRouteFd.Close()
 	}
+
+	closePressure(vars)
 }
 
 func showInit() showT {
 	var show showT
 
+	show.cpuUsage = true
+	show.diskIo = true
+	show.hwmon = true
 	show.cpuTemp = true
 	show.moboTemp = true
 	show.driveTemp = true
+	show.gpu = true
+	show.nvmeTemp = true
+	show.net = true
 	show.wifi = true
 	show.bat = true
+	show.pressure = true
 
 	return show
 }
@@ -0,0 +1,129 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strconv"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type zramDevT struct {
+	name     string
+	mmStatFd *os.File
+
+	// uncompressed size of the device, read once at startup
+	diskSize uint64
+}
+
+type zramT struct {
+	name     string
+	diskSize uint64
+
+	// data stored, its compressed size and the memory used for it
+	// including fragmentation and metadata
+	orig    uint64
+	compr   uint64
+	memUsed uint64
+
+	ratio float64
+}
+
+// detectZram opens mm_stat of every initialised zram device
+func detectZram(vars *varsT) {
+	zramDirs, _ := fp.Glob("/sys/block/zram*")
+	for _, dir := range zramDirs {
+		diskSize := readSysFloat(fp.Join(dir, "disksize"))
+		if diskSize == 0 {
+			continue
+		}
+
+		fd, err := os.Open(fp.Join(dir, "mm_stat"))
+		if err != nil {
+			continue
+		}
+
+		vars.zramDevs = append(vars.zramDevs, zramDevT{
+			name:     fp.Base(dir),
+			mmStatFd: fd,
+			diskSize: uint64(diskSize),
+		})
+	}
+}
+
+func getZramInfo(st *sttsT, vars *varsT) {
+	st.mem.zram = st.mem.zram[:0]
+
+	for _, dev := range vars.zramDevs {
+		z := zramT{name: dev.name, diskSize: dev.diskSize}
+
+		// orig_data_size compr_data_size mem_used_total mem_limit ...
+		fields := str.Fields(readSysLine(dev.mmStatFd, vars))
+		if len(fields) >= 3 {
+			z.orig, _ = strconv.ParseUint(fields[0], 10, 64)
+			z.compr, _ = strconv.ParseUint(fields[1], 10, 64)
+			z.memUsed, _ = strconv.ParseUint(fields[2], 10, 64)
+		}
+		if z.compr > 0 {
+			z.ratio = float64(z.orig) / float64(z.compr)
+		}
+
+		st.mem.zram = append(st.mem.zram, z)
+	}
+}
+
+// getThpMode returns the selected transparent hugepage mode, e.g. madvise
+// from "always [madvise] never"
+func getThpMode() string {
+	modes := readSysString("/sys/kernel/mm/transparent_hugepage/enabled")
+	_, mode, _ := str.Cut(modes, "[")
+	mode, _, _ = str.Cut(mode, "]")
+	return mode
+}
+
+// memPressure rates how tight memory is from the available memory, swap
+// usage and, when read, the memory stall averages, and lists what it is
+// based on
+func memPressure(st *sttsT, vars *varsT) (string, string) {
+	var availPerc, swapPerc float64
+	if st.mem.total > 0 {
+		availPerc = float64(st.mem.avail) / float64(st.mem.total) * 100
+	}
+	if st.mem.swapTotal > 0 {
+		swapPerc = float64(st.mem.swapUsed) / float64(st.mem.swapTotal) *
+			100
+	}
+
+	var psiSome, psiFull float64
+	hasPsi := vars.psiFds[1] != nil
+	if hasPsi {
+		psiSome = st.pressure.mem.some[0]
+		psiFull = st.pressure.mem.full[0]
+	}
+
+	level := "low"
+	switch {
+	case availPerc < 10 || psiSome >= 20 || psiFull >= 5:
+		level = "high"
+	case availPerc < 20 || swapPerc > 50 || psiSome >= 1:
+		level = "moderate"
+	}
+
+	info := []string{fmt.Sprintf("avail %.0f%%", availPerc)}
+	if st.mem.swapTotal > 0 {
+		info = append(info, fmt.Sprintf("swap %.0f%%", swapPerc))
+	}
+	if hasPsi {
+		info = append(info, fmt.Sprintf("psi %.2f/%.2f", psiSome, psiFull))
+	}
+	info = append(info, fmt.Sprintf("dirty %dM", st.mem.dirty/(1024*1024)))
+
+	return level, str.Join(info, " ")
+}
+
+func closeZram(vars *varsT) {
+	for _, dev := range vars.zramDevs {
+		dev.mmStatFd.Close()
+	}
+}
@@ -1,40 +1,208 @@
 package main
 
 import (
+	"bufio"
+	"fmt"
 	"net"
+	"os"
+	"strconv"
+	"time"
 
-	"github.com/mdlayher/wifi"
+	fp "path/filepath"
 )
 
-func detectWlan(vars *varsT) {
-	ifaces, err := net.Interfaces()
-	errExit(err)
-	for _, iface := range ifaces {
-		_ = iface.Name
+// shortest interval between two counter samples that still gives a
+// meaningful rate
+const rateSampleMin = 200 * time.Millisecond
+
+// waitSample sleeps until rateSampleMin passed since the previous sample of
+// counters, which are primed at detection and can be read right after
+func waitSample(prev time.Time, vars *varsT) {
+	since := time.Since(prev)
+	if !vars.bench && since < rateSampleMin {
+		time.Sleep(rateSampleMin - since)
 	}
+}
+
+type netT struct {
+	name  string
+	state string
+	addrs []string
+
+	rxBytes uint64
+	txBytes uint64
+
+	// bytes per second since the previous sample
+	rxRate float64
+	txRate float64
+}
+
+type netIfaceT struct {
+	name    string
+	rxFd    *os.File
+	txFd    *os.File
+	stateFd *os.File
 
-	vars.wifiClient, _ = wifi.New()
+	rxPrev uint64
+	txPrev uint64
+}
 
-	wifis, _ := vars.wifiClient.Interfaces()
-	for _, wifi := range wifis {
-		vars.wifiIface = wifi
-		// todo: add some filtering in case of multiple wifi devices
-		return
+func detectNet(vars *varsT) {
+	names := vars.netIfaceNames
+	if len(names) == 0 {
+		ifaces, err := net.Interfaces()
+		errExit(err)
+		for _, iface := range ifaces {
+			if iface.Flags&net.FlagLoopback != 0 {
+				continue
+			}
+			names = append(names, iface.Name)
+		}
 	}
-	vars.wifiClient = nil
-	vars.wifiIface = nil
+
+	for _, name := range names {
+		dir := fp.Join("/sys/class/net", name)
+
+		var iface netIfaceT
+		var err error
+		iface.name = name
+
+		iface.rxFd, err = os.Open(fp.Join(dir, "statistics/rx_bytes"))
+		if err != nil {
+			continue
+		}
+
+		iface.txFd, err = os.Open(fp.Join(dir, "statistics/tx_bytes"))
+		if err != nil {
+			iface.rxFd.Close()
+			continue
+		}
+
+		iface.stateFd, err = os.Open(fp.Join(dir, "operstate"))
+		if err != nil {
+			iface.stateFd = nil
+		}
+
+		iface.rxPrev = readCounter(iface.rxFd, vars)
+		iface.txPrev = readCounter(iface.txFd, vars)
+
+		vars.netIfaces = append(vars.netIfaces, iface)
+	}
+
+	vars.netTime = time.Now()
 }
 
-func getWifiInfo(st *sttsT, vars *varsT) {
-	if vars.wifiClient == nil {
-		return
+func getNetInfo(st *sttsT, vars *varsT) {
+	st.net = st.net[:0]
+
+	if len(vars.netIfaces) > 0 {
+		waitSample(vars.netTime, vars)
+	}
+
+	elapsed := time.Since(vars.netTime).Seconds()
+	vars.netTime = time.Now()
+
+	for i := range vars.netIfaces {
+		iface := &vars.netIfaces[i]
+
+		var n netT
+		n.name = iface.name
+		n.rxBytes = readCounter(iface.rxFd, vars)
+		n.txBytes = readCounter(iface.txFd, vars)
+
+		if elapsed > 0 {
+			n.rxRate = counterRate(iface.rxPrev, n.rxBytes, elapsed)
+			n.txRate = counterRate(iface.txPrev, n.txBytes, elapsed)
+		}
+		iface.rxPrev = n.rxBytes
+		iface.txPrev = n.txBytes
+
+		n.state = "unknown"
+		if iface.stateFd != nil {
+			rd := bufio.NewReaderSize(iface.stateFd, 16)
+			stateBin, _, _ := rd.ReadLine()
+			n.state = string(stateBin)
+
+			// skip for benchmarking as this poses a large i/o bottleneck
+			if !vars.bench {
+				iface.stateFd.Seek(0, 0)
+			}
+		}
+
+		n.addrs = getNetAddrs(iface.name)
+
+		st.net = append(st.net, n)
 	}
+}
+
+func getNetAddrs(name string) []string {
+	var res []string
+
+	iface, err := net.InterfaceByName(name)
+	if err != nil {
+		return res
+	}
+
+	addrs, err := iface.Addrs()
+	if err != nil {
+		return res
+	}
+
+	for _, addr := range addrs {
+		res = append(res, addr.String())
+	}
+
+	return res
+}
+
+func readCounter(fd *os.File, vars *varsT) uint64 {
+	rd := bufio.NewReaderSize(fd, 24)
+	lineBin, _, err := rd.ReadLine()
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		fd.Seek(0, 0)
+	}
+
+	if err != nil {
+		return 0
+	}
+
+	val, _ := strconv.ParseUint(string(lineBin), 10, 64)
+
+	return val
+}
+
+func counterRate(prev, cur uint64, elapsed float64) float64 {
+	if cur < prev {
+		return 0
+	}
+	return float64(cur-prev) / elapsed
+}
+
+func fmtBytes(b float64) string {
+	switch {
+	case b >= 1024*1024*1024:
+		return fmt.Sprintf("%.1fG", b/(1024*1024*1024))
+	case b >= 1024*1024:
+		return fmt.Sprintf("%.1fM", b/(1024*1024))
+	case b >= 1024:
+		return fmt.Sprintf("%.0fK", b/1024)
+	default:
+		return fmt.Sprintf("%.0fB", b)
+	}
+}
+
+func fmtNet(n netT) string {
+	return fmt.Sprintf("%s ↓%s ↑%s", n.name,
+		fmtBytes(n.rxRate), fmtBytes(n.txRate))
+}
 
-	bss, _ := vars.wifiClient.BSS(vars.wifiIface)
-	infos, _ := vars.wifiClient.StationInfo(vars.wifiIface)
-	st.wifiBss = bss
-	for _, info := range infos {
-		st.wifiInfo = info
-		break
+// netShown tells whether an interface goes into the one-line output; without
+// an explicit net_iface list only interfaces that are up are shown
+func netShown(n netT, vars *varsT) bool {
+	if len(vars.netIfaceNames) > 0 {
+		return true
 	}
+	return n.state == "up" || n.state == "unknown"
 }
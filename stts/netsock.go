@@ -0,0 +1,180 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+
+	str "strings"
+)
+
+type procSocksT struct {
+	pid  string
+	user string
+	cmd  string
+
+	// listeners and connections, and how many other unix sockets
+	socks    []sockT
+	unixConn int
+}
+
+var sockStateOrder = map[string]int{
+	"LISTEN":      0,
+	"UNCONN":      1,
+	"ESTABLISHED": 2,
+}
+
+// printSockets lists the listening and connected sockets of every process
+// after the socket counts by state; sockets of other network namespaces
+// aren't in /proc/net and so aren't shown
+func printSockets(st *sttsT, vars *varsT) {
+	getAllInfo(st, vars)
+	getProcInfo(st, vars)
+	socks := readSockets("/proc/net")
+
+	owned := make(map[uint64]bool)
+	var procs []procSocksT
+	for _, p := range filterProcs(st.ps, vars) {
+		ps := procSocksT{pid: p.pid, user: getUserName(p.uid)}
+		ps.cmd = str.ReplaceAll(p.args, "\n", " ")
+		if ps.cmd == "" {
+			ps.cmd = p.stat.comm
+		}
+
+		inodes := make(map[uint64]bool)
+		for _, file := range p.files {
+			if inode, ok := getSockInode(file); ok {
+				inodes[inode] = true
+				owned[inode] = true
+			}
+		}
+
+		// processes with only connected unix sockets are left out
+		addProcSocks(&ps, socks, inodes)
+		if len(ps.socks) > 0 {
+			procs = append(procs, ps)
+		}
+	}
+
+	sort.SliceStable(procs, func(i, j int) bool {
+		a, _ := strconv.Atoi(procs[i].pid)
+		b, _ := strconv.Atoi(procs[j].pid)
+		return a < b
+	})
+	if vars.top.limit > 0 && len(procs) > vars.top.limit {
+		procs = procs[:vars.top.limit]
+	}
+
+	// listeners of processes that can't be read, e.g. of other users
+	// without root, would be missed otherwise
+	if vars.top.user == "" && vars.top.match == nil {
+		unknown := procSocksT{pid: "-", user: "-", cmd: "(unknown owner)"}
+		notOwned := make(map[uint64]bool)
+		for _, s := range socks {
+			if owned[s.inode] || s.inode == 0 ||
+				(s.proto == "unix" && s.state != "LISTEN") {
+				continue
+			}
+			notOwned[s.inode] = true
+		}
+		addProcSocks(&unknown, socks, notOwned)
+		if len(unknown.socks) > 0 {
+			procs = append(procs, unknown)
+		}
+	}
+
+	// the processes are listed by pid with their sockets
+	if vars.format != "" {
+		st.ps = nil
+		st.socks = procs
+		printExport(st, vars)
+		return
+	}
+
+	printOneLineOnce(st, vars)
+	sep()
+	printSockCounts(socks)
+	sep()
+	printSockTable(procs)
+}
+
+// addProcSocks adds the sockets with one of the inodes that are listening,
+// bound or connected; unix sockets other than listeners are only counted
+func addProcSocks(ps *procSocksT, socks []sockT, inodes map[uint64]bool) {
+	for _, s := range socks {
+		if !inodes[s.inode] {
+			continue
+		}
+
+		switch {
+		case s.proto == "unix" && s.state != "LISTEN":
+			ps.unixConn++
+		case s.state == "LISTEN" || s.state == "ESTABLISHED":
+			ps.socks = append(ps.socks, s)
+		case s.state == "UNCONN" && !str.HasSuffix(s.local, ":0"):
+			ps.socks = append(ps.socks, s)
+		}
+	}
+
+	sort.SliceStable(ps.socks, func(i, j int) bool {
+		a, b := ps.socks[i], ps.socks[j]
+		if a.state != b.state {
+			return sockStateOrder[a.state] < sockStateOrder[b.state]
+		}
+		if a.proto != b.proto {
+			return a.proto < b.proto
+		}
+		return a.local < b.local
+	})
+}
+
+// printSockCounts prints how many sockets there are in each state with ipv4
+// and ipv6 counted together
+func printSockCounts(socks []sockT) {
+	counts := make(map[string]map[string]int)
+	for _, s := range socks {
+		proto := str.TrimSuffix(s.proto, "6")
+		if counts[proto] == nil {
+			counts[proto] = make(map[string]int)
+		}
+		counts[proto][s.state]++
+	}
+
+	for _, proto := range []string{"tcp", "udp", "unix"} {
+		var states []string
+		for state := range counts[proto] {
+			states = append(states, state)
+		}
+		sort.Strings(states)
+
+		var out []string
+		for _, state := range states {
+			out = append(out, fmt.Sprintf("%s %d", state,
+				counts[proto][state]))
+		}
+		if len(out) == 0 {
+			out = append(out, "none")
+		}
+		prStrL(proto, str.Join(out, "  "))
+	}
+}
+
+func printSockTable(procs []procSocksT) {
+	fmt.Printf("%7s %-10s %s\n", "PID", "USER", "COMMAND")
+
+	for _, ps := range procs {
+		fmt.Printf("%7s %-10.10s %s\n", ps.pid, ps.user, ps.cmd)
+
+		for _, s := range ps.socks {
+			addr := s.local
+			if s.state == "ESTABLISHED" {
+				addr += " -> " + s.remote
+			}
+			fmt.Printf("%9s%-5s %-11s %s\n", "", s.proto, s.state, addr)
+		}
+		if ps.unixConn > 0 {
+			fmt.Printf("%9s%-5s %-11s %d sockets\n", "", "unix", "other",
+				ps.unixConn)
+		}
+	}
+}
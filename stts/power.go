@@ -6,102 +6,233 @@ import (
 	"os"
 	"strconv"
 
+	fp "path/filepath"
 	str "strings"
 )
 
-func detectBat(vars *varsT) {
-	var err error
-	batLoc := "/sys/class/power_supply/BAT0/"
+type batDevT struct {
+	name string
 
-	vars.batCapacityFd, err = os.Open(batLoc + "capacity")
-	if err != nil {
-		vars.batCapacityFd = nil
-	}
+	capacityFd   *os.File
+	energyFd     *os.File
+	energyFullFd *os.File
+	powerFd      *os.File
+	statusFd     *os.File
+
+	// static attributes, read once at startup
+	energyFullDesign float64
+	cycles           int
+	chargeStart      string
+	chargeEnd        string
+}
+
+type batT struct {
+	name   string
+	level  int
+	status string
+
+	// in µWh and µW, or µAh and µA for batteries only reporting charge
+	energy     float64
+	energyFull float64
+	power      float64
 
-	vars.batEnergyFd, err = os.Open(batLoc + "energy_now")
+	cycles int
+
+	// lost capacity in percent of the design capacity
+	wear float64
+
+	chargeStart string
+	chargeEnd   string
+}
+
+// detectBat opens every battery and ac adapter in power_supply; batteries
+// of peripherals like mice have a "Device" scope and are skipped
+func detectBat(vars *varsT) {
+	supplyDirs, err := fp.Glob("/sys/class/power_supply/*")
 	if err != nil {
-		vars.batEnergyFd, err = os.Open(batLoc + "charge_now")
-		if err != nil {
-			vars.batEnergyFd = nil
-		}
+		return
 	}
 
-	vars.batEnergyFullFd, err = os.Open(batLoc + "energy_full")
-	if err != nil {
-		vars.batEnergyFullFd, err = os.Open(batLoc + "charge_full")
-		if err != nil {
-			vars.batEnergyFullFd = nil
+	for _, dir := range supplyDirs {
+		switch readSysString(fp.Join(dir, "type")) {
+		case "Battery":
+			if readSysString(fp.Join(dir, "scope")) == "Device" {
+				continue
+			}
+
+			bat := detectBatDev(dir)
+			if bat.capacityFd == nil {
+				continue
+			}
+			vars.bats = append(vars.bats, bat)
+		case "Mains", "USB":
+			fd, err := os.Open(fp.Join(dir, "online"))
+			if err != nil {
+				continue
+			}
+			vars.acOnlineFds = append(vars.acOnlineFds, fd)
 		}
 	}
+}
 
-	vars.batPowerFd, err = os.Open(batLoc + "power_now")
-	if err != nil {
-		vars.batPowerFd, err = os.Open(batLoc + "current_now")
-		if err != nil {
-			vars.batPowerFd = nil
-		}
+func detectBatDev(dir string) batDevT {
+	bat := batDevT{name: fp.Base(dir)}
+
+	bat.capacityFd = openFirst(fp.Join(dir, "capacity"))
+	bat.energyFd = openFirst(fp.Join(dir, "energy_now"),
+		fp.Join(dir, "charge_now"))
+	bat.energyFullFd = openFirst(fp.Join(dir, "energy_full"),
+		fp.Join(dir, "charge_full"))
+	bat.powerFd = openFirst(fp.Join(dir, "power_now"),
+		fp.Join(dir, "current_now"))
+	bat.statusFd = openFirst(fp.Join(dir, "status"))
+
+	bat.energyFullDesign = readSysFloat(fp.Join(dir, "energy_full_design"))
+	if bat.energyFullDesign == 0 {
+		bat.energyFullDesign = readSysFloat(
+			fp.Join(dir, "charge_full_design"))
 	}
 
-	vars.batStatusFd, err = os.Open(batLoc + "status")
-	if err != nil {
-		vars.batStatusFd = nil
+	bat.cycles = int(readSysFloat(fp.Join(dir, "cycle_count")))
+
+	// thinkpads used to have charge_start/stop_threshold
+	bat.chargeStart = readSysString(
+		fp.Join(dir, "charge_control_start_threshold"))
+	if bat.chargeStart == "" {
+		bat.chargeStart = readSysString(
+			fp.Join(dir, "charge_start_threshold"))
+	}
+	bat.chargeEnd = readSysString(
+		fp.Join(dir, "charge_control_end_threshold"))
+	if bat.chargeEnd == "" {
+		bat.chargeEnd = readSysString(
+			fp.Join(dir, "charge_stop_threshold"))
 	}
+
+	return bat
 }
 
+// getBatInfo reads all batteries and combines them into one level and time
+// left as if they were a single battery
 func getBatInfo(st *sttsT, vars *varsT) {
-	st.batLevel = readShortString(vars.batCapacityFd)
+	if len(st.bats) != len(vars.bats) {
+		st.bats = make([]batT, len(vars.bats))
+	}
+
+	var energy, energyFull, power, levelSum float64
+	var charging, discharging bool
+
+	for i, dev := range vars.bats {
+		bat := &st.bats[i]
+		bat.name = dev.name
+		bat.cycles = dev.cycles
+		bat.chargeStart = dev.chargeStart
+		bat.chargeEnd = dev.chargeEnd
+
+		level, _ := readSysInt(dev.capacityFd, vars)
+		bat.level = int(level)
+		bat.energy = readBatVal(dev.energyFd, vars)
+		bat.energyFull = readBatVal(dev.energyFullFd, vars)
+		bat.power = readBatVal(dev.powerFd, vars)
+		bat.status = readSysLine(dev.statusFd, vars)
+
+		bat.wear = 0
+		if dev.energyFullDesign > 0 && bat.energyFull > 0 {
+			bat.wear = (1 - bat.energyFull/dev.energyFullDesign) * 100
+		}
 
-	batEnergy := readPower(vars.batEnergyFd)
-	batEnergyFull := readPower(vars.batEnergyFullFd)
-	batPower := readPower(vars.batPowerFd)
-	batStatus := readShortString(vars.batStatusFd)
+		energy += bat.energy
+		energyFull += bat.energyFull
+		power += bat.power
+		levelSum += float64(bat.level)
+
+		switch bat.status {
+		case "Charging":
+			charging = true
+		case "Discharging":
+			discharging = true
+		}
+	}
+
+	// batteries without energy readings are weighed equally
+	var level float64
+	if energyFull > 0 {
+		level = energy / energyFull * 100
+	} else if len(st.bats) > 0 {
+		level = levelSum / float64(len(st.bats))
+	}
+	st.batLevel = strconv.Itoa(int(level + 0.5))
 
 	var minLeft int
 
-	if batStatus == "Dis" && batPower != 0 {
-		minLeft = int(batEnergy / batPower * 60)
-	} else if batStatus == "Cha" && batPower != 0 {
-		minLeft = int((batEnergyFull - batEnergy) / batPower * 60)
+	if discharging && power != 0 {
+		minLeft = int(energy / power * 60)
+	} else if charging && power != 0 {
+		minLeft = int((energyFull - energy) / power * 60)
 	}
 
+	st.batMinLeft = minLeft
 	st.batTimeLeft = fmt.Sprintf("%d:%2.2d", minLeft/60, minLeft%60)
 
-	// skip for benchmarking as this poses a large i/o bottleneck
-	if !vars.bench {
-		vars.batCapacityFd.Seek(0, 0)
-		vars.batEnergyFd.Seek(0, 0)
-		vars.batEnergyFullFd.Seek(0, 0)
-		vars.batPowerFd.Seek(0, 0)
-		vars.batStatusFd.Seek(0, 0)
+	st.acOnline = false
+	for _, fd := range vars.acOnlineFds {
+		online, _ := readSysInt(fd, vars)
+		if online == 1 {
+			st.acOnline = true
+		}
 	}
 }
 
-func readShortString(fd *os.File) string {
-	rd := bufio.NewReaderSize(fd, 3)
-	var levelBin [3]byte
-	_, err := rd.Read(levelBin[:])
+func readBatVal(fd *os.File, vars *varsT) float64 {
+	if fd == nil {
+		return 0
+	}
+
+	val, err := readSysInt(fd, vars)
 	if err != nil {
-		return "0"
+		return 0
 	}
 
-	levelFields := str.Fields(string(levelBin[:]))
+	// some firmwares report a negative current while discharging
+	if val < 0 {
+		val = -val
+	}
 
-	return levelFields[0]
+	return float64(val)
 }
 
-func readPower(fd *os.File) float64 {
-	rd := bufio.NewReaderSize(fd, 10)
-	var powerBin [10]byte
-	_, err := rd.Read(powerBin[:])
-	if err != nil {
-		return 0
+// readSysLine reads a whole string sysfs attribute, e.g. a battery status
+func readSysLine(fd *os.File, vars *varsT) string {
+	if fd == nil {
+		return ""
+	}
+
+	rd := bufio.NewReaderSize(fd, 256)
+	lineBin, _, err := rd.ReadLine()
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		fd.Seek(0, 0)
 	}
 
-	powerStr := str.Fields(string(powerBin[:]))
-	power, err := strconv.ParseFloat(powerStr[0], 64)
 	if err != nil {
-		return 0
+		return ""
 	}
 
-	return power
+	return str.TrimSpace(string(lineBin))
+}
+
+func closeBat(vars *varsT) {
+	for _, bat := range vars.bats {
+		for _, fd := range []*os.File{bat.capacityFd, bat.energyFd,
+			bat.energyFullFd, bat.powerFd, bat.statusFd} {
+			if fd != nil {
+				fd.Close()
+			}
+		}
+	}
+
+	for _, fd := range vars.acOnlineFds {
+		fd.Close()
+	}
 }
@@ -0,0 +1,266 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type pressureT struct {
+	cpu psiT
+	mem psiT
+	io  psiT
+
+	// totals since boot from /proc/vmstat
+	majFaults uint64
+	oomKills  uint64
+	swapIn    uint64
+	swapOut   uint64
+
+	// per second since the previous sample
+	majFaultRate float64
+	swapInRate   float64
+	swapOutRate  float64
+
+	// thermal throttle events since boot summed over cores and packages
+	coreThrottle uint64
+	pkgThrottle  uint64
+}
+
+// psiT holds the share of time in percent some or all tasks were stalled on
+// a resource over 10s, 60s and 300s, and the total stall time in µs
+type psiT struct {
+	some      [3]float64
+	full      [3]float64
+	someTotal uint64
+	fullTotal uint64
+}
+
+type vmstatT struct {
+	majFaults uint64
+	oomKills  uint64
+	swapIn    uint64
+	swapOut   uint64
+}
+
+var psiResources = []string{"cpu", "memory", "io"}
+
+func detectPressure(vars *varsT) {
+	for i, res := range psiResources {
+		fd, err := os.Open("/proc/pressure/" + res)
+		if err != nil {
+			continue
+		}
+		vars.psiFds[i] = fd
+	}
+
+	fd, err := os.Open("/proc/vmstat")
+	if err == nil {
+		vars.vmstatFd = fd
+		vars.vmstatPrev = readVmstat(vars)
+		vars.vmstatTime = time.Now()
+	}
+
+	// the package count is the same on every cpu of a package, so it is
+	// read once per package
+	cpuDirs, _ := fp.Glob("/sys/devices/system/cpu/cpu[0-9]*")
+	var pkgs []string
+	for _, dir := range cpuDirs {
+		throttleDir := fp.Join(dir, "thermal_throttle")
+		if !fileExists(throttleDir) {
+			continue
+		}
+
+		fd, err := os.Open(fp.Join(throttleDir, "core_throttle_count"))
+		if err == nil {
+			vars.coreThrottleFds = append(vars.coreThrottleFds, fd)
+		}
+
+		pkg := readSysString(fp.Join(dir, "topology/physical_package_id"))
+		if elInSlice(pkgs, pkg) {
+			continue
+		}
+		pkgs = append(pkgs, pkg)
+
+		fd, err = os.Open(fp.Join(throttleDir, "package_throttle_count"))
+		if err == nil {
+			vars.pkgThrottleFds = append(vars.pkgThrottleFds, fd)
+		}
+	}
+}
+
+func getPressureInfo(st *sttsT, vars *varsT) {
+	if !vars.show.pressure {
+		return
+	}
+
+	p := &st.pressure
+	for i, psi := range []*psiT{&p.cpu, &p.mem, &p.io} {
+		if vars.psiFds[i] != nil {
+			*psi = readPsi(vars.psiFds[i], vars)
+		}
+	}
+
+	if vars.vmstatFd != nil {
+		vm := readVmstat(vars)
+		elapsed := time.Since(vars.vmstatTime).Seconds()
+
+		p.majFaults = vm.majFaults
+		p.oomKills = vm.oomKills
+		p.swapIn = vm.swapIn
+		p.swapOut = vm.swapOut
+
+		p.majFaultRate, p.swapInRate, p.swapOutRate = 0, 0, 0
+		if elapsed > 0 {
+			prev := vars.vmstatPrev
+			p.majFaultRate = counterRate(prev.majFaults, vm.majFaults,
+				elapsed)
+			p.swapInRate = counterRate(prev.swapIn, vm.swapIn, elapsed)
+			p.swapOutRate = counterRate(prev.swapOut, vm.swapOut, elapsed)
+		}
+
+		vars.vmstatPrev = vm
+		vars.vmstatTime = time.Now()
+	}
+
+	p.coreThrottle = sumThrottle(vars.coreThrottleFds, vars)
+	p.pkgThrottle = sumThrottle(vars.pkgThrottleFds, vars)
+}
+
+// readPsi parses lines such as
+// some avg10=0.12 avg60=0.05 avg300=0.01 total=12345
+func readPsi(fd *os.File, vars *varsT) psiT {
+	var psi psiT
+
+	bin, err := io.ReadAll(fd)
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		fd.Seek(0, 0)
+	}
+
+	if err != nil {
+		return psi
+	}
+
+	input := bufio.NewScanner(str.NewReader(string(bin)))
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) != 5 {
+			continue
+		}
+
+		var avgs [3]float64
+		var total uint64
+		for _, field := range fields[1:] {
+			key, val, _ := str.Cut(field, "=")
+			switch key {
+			case "avg10":
+				avgs[0], _ = strconv.ParseFloat(val, 64)
+			case "avg60":
+				avgs[1], _ = strconv.ParseFloat(val, 64)
+			case "avg300":
+				avgs[2], _ = strconv.ParseFloat(val, 64)
+			case "total":
+				total, _ = strconv.ParseUint(val, 10, 64)
+			}
+		}
+
+		switch fields[0] {
+		case "some":
+			psi.some, psi.someTotal = avgs, total
+		case "full":
+			psi.full, psi.fullTotal = avgs, total
+		}
+	}
+
+	return psi
+}
+
+func readVmstat(vars *varsT) vmstatT {
+	var vm vmstatT
+
+	rd := bufio.NewReaderSize(vars.vmstatFd, 4096)
+	for {
+		lineBin, _, err := rd.ReadLine()
+		if err != nil {
+			break
+		}
+
+		key, val, _ := str.Cut(string(lineBin), " ")
+		switch key {
+		case "pgmajfault":
+			vm.majFaults, _ = strconv.ParseUint(val, 10, 64)
+		case "oom_kill":
+			vm.oomKills, _ = strconv.ParseUint(val, 10, 64)
+		case "pswpin":
+			vm.swapIn, _ = strconv.ParseUint(val, 10, 64)
+		case "pswpout":
+			vm.swapOut, _ = strconv.ParseUint(val, 10, 64)
+		}
+	}
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		vars.vmstatFd.Seek(0, 0)
+	}
+
+	return vm
+}
+
+func sumThrottle(fds []*os.File, vars *varsT) uint64 {
+	var sum uint64
+	for _, fd := range fds {
+		val, err := readSysInt(fd, vars)
+		if err == nil && val > 0 {
+			sum += uint64(val)
+		}
+	}
+	return sum
+}
+
+func closePressure(vars *varsT) {
+	for _, fd := range vars.psiFds {
+		if fd != nil {
+			fd.Close()
+		}
+	}
+
+	if vars.vmstatFd != nil {
+		vars.vmstatFd.Close()
+	}
+
+	for _, fd := range vars.coreThrottleFds {
+		fd.Close()
+	}
+	for _, fd := range vars.pkgThrottleFds {
+		fd.Close()
+	}
+}
+
+// fmtPressureSeg formats a one-line segment enabled by pressure_seg
+func fmtPressureSeg(seg string, st *sttsT) string {
+	p := st.pressure
+
+	switch seg {
+	case "psi":
+		return fmt.Sprintf("psi %.1f %.1f %.1f", p.cpu.some[0],
+			p.mem.some[0], p.io.some[0])
+	case "faults":
+		return fmt.Sprintf("majflt %.0f/s", p.majFaultRate)
+	case "swapio":
+		return fmt.Sprintf("si %.0f/s so %.0f/s", p.swapInRate,
+			p.swapOutRate)
+	case "oom":
+		return fmt.Sprintf("oom %d", p.oomKills)
+	case "throttle":
+		return fmt.Sprintf("thr %d", p.coreThrottle+p.pkgThrottle)
+	}
+	return ""
+}
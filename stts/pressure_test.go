@@ -0,0 +1,76 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func openTestFile(t *testing.T, content string) *os.File {
+	file := filepath.Join(t.TempDir(), "file")
+	err := os.WriteFile(file, []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	fd, err := os.Open(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { fd.Close() })
+
+	return fd
+}
+
+func TestReadPsi(t *testing.T) {
+	tests := []struct {
+		name    string
+		content string
+		want    psiT
+	}{
+		{"some and full",
+			"some avg10=1.50 avg60=0.75 avg300=0.25 total=123456\n" +
+				"full avg10=0.50 avg60=0.10 avg300=0.00 total=6543\n",
+			psiT{some: [3]float64{1.5, 0.75, 0.25}, someTotal: 123456,
+				full: [3]float64{0.5, 0.1, 0}, fullTotal: 6543}},
+		{"cpu of older kernels without full",
+			"some avg10=0.12 avg60=0.05 avg300=0.01 total=12345\n",
+			psiT{some: [3]float64{0.12, 0.05, 0.01}, someTotal: 12345}},
+		{"fields in any order",
+			"some total=7 avg300=3.00 avg60=2.00 avg10=1.00\n",
+			psiT{some: [3]float64{1, 2, 3}, someTotal: 7}},
+		{"malformed lines are skipped",
+			"some avg10=1.00 avg60=2.00\n" +
+				"other avg10=1.00 avg60=2.00 avg300=3.00 total=4\n" +
+				"full avg10=x avg60=2.00 avg300=3.00 total=4\n",
+			psiT{full: [3]float64{0, 2, 3}, fullTotal: 4}},
+		{"empty", "", psiT{}},
+	}
+
+	vars := &varsT{}
+	for _, tt := range tests {
+		fd := openTestFile(t, tt.content)
+
+		// the fd is rewound for the next sample
+		for i := 0; i < 2; i++ {
+			if got := readPsi(fd, vars); got != tt.want {
+				t.Errorf("%s: read %d = %+v, want %+v", tt.name, i, got,
+					tt.want)
+			}
+		}
+	}
+}
+
+func TestReadVmstat(t *testing.T) {
+	vars := &varsT{}
+	vars.vmstatFd = openTestFile(t, "nr_free_pages 1000\n"+
+		"pswpin 12\npswpout 34\npgmajfault 567\noom_kill 2\n"+
+		"pgfault 99999\n")
+
+	want := vmstatT{majFaults: 567, oomKills: 2, swapIn: 12, swapOut: 34}
+	for i := 0; i < 2; i++ {
+		if got := readVmstat(vars); got != want {
+			t.Errorf("read %d = %+v, want %+v", i, got, want)
+		}
+	}
+}
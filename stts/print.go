@@ -2,102 +2,167 @@ package main
 
 import (
 	"fmt"
+	"os"
+	"strconv"
 	"time"
 
+	fp "path/filepath"
 	str "strings"
 )
 
 func printOneLine(st *sttsT, vars *varsT) {
 	for {
 		getAllInfo(st, vars)
+		checkAlerts(st, vars)
 		printOneLineOnce(st, vars)
 		time.Sleep(5 * time.Second)
 	}
 }
 
 func printOneLineOnce(st *sttsT, vars *varsT) {
+	if vars.format != "" {
+		printExport(st, vars)
+		return
+	}
+
 	var out []string
 
-	load := fmt.Sprintf("load %.2f", st.loads[0])
+	if len(st.addInfo) > 0 {
+		out = append(out, st.addInfo...)
+	}
+
+	out = append(out, fmtLoad(st))
 
-	var mem string
-	memUsed := float64(st.mem.used) / (1024 * 1024)
-	if memUsed > 1024 {
-		mem = fmt.Sprintf("mem %.1fG", memUsed/1024)
-	} else {
-		mem = fmt.Sprintf("mem %.0fM", memUsed)
+	if vars.has.cpuUsage {
+		out = append(out, fmtCpu(st))
 	}
 
-	var disk string
-	if st.rootDiskFree > 1024 {
-		disk = fmt.Sprintf("df %.1fG", st.rootDiskFree/1024)
+	out = append(out, fmtMem(st))
+	if vars.diskConf {
+		for _, d := range st.disks {
+			out = append(out, fmtDiskMount(d))
+		}
 	} else {
-		disk = fmt.Sprintf("df %.0fM", st.rootDiskFree)
+		out = append(out, fmtDisk(st))
 	}
 
-	var temps, sep string
-	if vars.has.cpu1Temp && !vars.has.cpu2Temp {
-		temps += fmt.Sprintf("cpu %s°C", st.cpu1Temp)
-		sep = " "
-	} else if vars.has.cpu1Temp && vars.has.cpu2Temp {
-		temps += fmt.Sprintf("cpu1 %s°C", st.cpu1Temp)
-		temps += fmt.Sprintf(" cpu2 %s°C", st.cpu2Temp)
-		sep = " "
+	for _, io := range st.diskIo {
+		if elInSlice(vars.diskIoDevs, io.name) {
+			out = append(out, fmtDiskIo(io))
+		}
 	}
 
-	if vars.has.moboTemp {
-		temps += sep + fmt.Sprintf("mb %s°C", st.moboTemp)
-		sep = " "
+	var temps []string
+	for _, t := range getTempSegs(st, vars) {
+		temps = append(temps, t.text)
 	}
 
-	if vars.has.driveTemp {
-		temps += sep + fmt.Sprintf("d %s°C", st.driveTemp)
-		sep = " "
+	if len(temps) > 0 {
+		out = append(out, str.Join(temps, " "))
 	}
 
-	var wifi string
-	if vars.has.wifi {
-		wifi = vars.wifiIface.Name
-		if st.wifiBss != nil && st.wifiBss.SSID != "" {
-			wifi += fmt.Sprintf(" %s %ddBm",
-				st.wifiBss.SSID, st.wifiInfo.Signal)
-		} else {
-			wifi += " no conn"
+	for _, n := range st.net {
+		if netShown(n, vars) {
+			out = append(out, fmtNet(n))
 		}
 	}
 
-	var bat string
-	if vars.has.bat {
-		bat = "bat " + st.batLevel + "%"
-		if st.batTimeLeft != "0:00" {
-			bat += " " + st.batTimeLeft
+	for _, w := range st.wifis {
+		out = append(out, fmtWifi(w))
+	}
+
+	if vars.show.vpn {
+		out = append(out, fmtVpn(st))
+	}
+
+	if vars.show.pressure {
+		for _, seg := range vars.pressureSegs {
+			out = append(out, fmtPressureSeg(seg, st))
 		}
 	}
 
-	if len(st.addInfo) > 0 {
-		out = append(out, st.addInfo...)
+	if vars.has.bat {
+		out = append(out, fmtBat(st))
 	}
 
-	out = append(out, load)
-	out = append(out, mem)
-	out = append(out, disk)
+	fmt.Printf("%s\n", str.Join(out, " | "))
+}
+
+type tempSegT struct {
+	group string
+	text  string
 
-	if temps != "" {
-		out = append(out, temps)
+	// aggregated temperature in the configured unit
+	temp float64
+}
+
+func fmtLoad(st *sttsT) string {
+	return fmt.Sprintf("load %.2f", st.loads[0])
+}
+
+func fmtCpu(st *sttsT) string {
+	return fmt.Sprintf("cpu %.0f%%", st.cpu.total.busy)
+}
+
+func fmtMem(st *sttsT) string {
+	memUsed := float64(st.mem.used) / (1024 * 1024)
+	if memUsed > 1024 {
+		return fmt.Sprintf("mem %.1fG", memUsed/1024)
 	}
+	return fmt.Sprintf("mem %.0fM", memUsed)
+}
 
-	if wifi != "" {
-		out = append(out, wifi)
+func fmtDisk(st *sttsT) string {
+	if st.rootDiskFree > 1024 {
+		return fmt.Sprintf("df %.1fG", st.rootDiskFree/1024)
 	}
+	return fmt.Sprintf("df %.0fM", st.rootDiskFree)
+}
+
+func getTempSegs(st *sttsT, vars *varsT) []tempSegT {
+	var segs []tempSegT
 
-	if bat != "" {
-		out = append(out, bat)
+	for _, t := range st.temps {
+		segs = append(segs, tempSegT{
+			group: t.name,
+			text:  t.label + " " + fmtTempGroup(t, vars),
+			temp:  tempVal(t.temp, vars),
+		})
 	}
 
-	fmt.Printf("%s\n", str.Join(out, " | "))
+	return segs
+}
+
+func fmtBat(st *sttsT) string {
+	bat := "bat " + st.batLevel + "%"
+	if st.batTimeLeft != "0:00" {
+		bat += " " + st.batTimeLeft
+	}
+	if st.acOnline {
+		bat += " ac"
+	}
+	return bat
+}
+
+// fmtChargeThresholds formats the levels at which charging starts and stops,
+// either can be missing depending on the firmware
+func fmtChargeThresholds(bat batT) string {
+	var thresholds []string
+	if bat.chargeStart != "" {
+		thresholds = append(thresholds, "start "+bat.chargeStart+"%")
+	}
+	if bat.chargeEnd != "" {
+		thresholds = append(thresholds, "stop "+bat.chargeEnd+"%")
+	}
+	return str.Join(thresholds, " ")
 }
 
 func printAll(st *sttsT, vars *varsT) {
+	if vars.format != "" {
+		printExport(st, vars)
+		return
+	}
+
 	upDays := int(st.uptime.Hours() / 24)
 	upHours := int(st.uptime.Hours()) % 24
 	up := fmt.Sprintf("%dd%dh", upDays, upHours)
@@ -109,10 +174,52 @@ func printAll(st *sttsT, vars *varsT) {
 	prFloat("load 15m", st.loads[2])
 	sep()
 
+	if vars.has.cpuUsage {
+		prFloat("cpu busy %", st.cpu.total.busy)
+		prFloat("cpu user %", st.cpu.total.user)
+		prFloat("cpu system %", st.cpu.total.system)
+		prFloat("cpu iowait %", st.cpu.total.iowait)
+		prFloat("cpu steal %", st.cpu.total.steal)
+		if len(st.cpu.freqs) > 0 {
+			prInt("cpu avg MHz", avgCpuFreq(st.cpu.freqs))
+		}
+		sep()
+
+		for _, core := range st.cpu.cores {
+			usage := fmt.Sprintf("%.1f%%", core.busy)
+			if freq, ok := st.cpu.freqs[core.name]; ok {
+				usage += fmt.Sprintf(" %dMHz", freq)
+			}
+			prStr(core.name, usage)
+		}
+		sep()
+	}
+
 	prInt("process count", st.procs)
-	prFloat("/ disk free", st.rootDiskFree/1024)
 	sep()
 
+	gb := float64(1024 * 1024 * 1024)
+	for _, d := range st.disks {
+		prStr("mount", d.path)
+		prFloat("disk size GB", float64(d.total)/gb)
+		prFloat("disk used GB", float64(d.used)/gb)
+		prFloat("disk free GB", float64(d.free)/gb)
+		prInt("inodes", int(d.inodes))
+		prFloat("inodes used %", d.inodesUsedPerc())
+		sep()
+	}
+
+	for _, io := range st.diskIo {
+		prStr("block device", io.name)
+		prFloat("read KB/s", io.readBytes/1024)
+		prFloat("write KB/s", io.writeBytes/1024)
+		prFloat("read IOPS", io.readOps)
+		prFloat("write IOPS", io.writeOps)
+		prInt("in flight", int(io.inFlight))
+		prFloat("await ms", io.await)
+		sep()
+	}
+
 	mb := 1024 * 1024
 	prInt("total mem", st.mem.total/mb)
 	prInt("used mem", st.mem.used/mb)
@@ -123,31 +230,151 @@ func printAll(st *sttsT, vars *varsT) {
 	prInt("buff/cache", (st.mem.buffer+st.mem.cache)/mb)
 	prInt("available", st.mem.avail/mb)
 	prInt("hugepages", st.mem.huge/mb)
+	prInt("dirty", st.mem.dirty/mb)
+	prInt("writeback", st.mem.writeback/mb)
+	prInt("thp anon", st.mem.anonHuge/mb)
+	if vars.thpMode != "" {
+		prStr("thp mode", vars.thpMode)
+	}
 	sep()
 
-	prStr("cpu1 temp", st.cpu1Temp)
-	prStr("cpu2 temp", st.cpu2Temp)
-	prStr("drive temp", st.driveTemp)
-	prStr("mobo temp", st.moboTemp)
+	if st.mem.swapTotal > 0 {
+		prInt("total swap", st.mem.swapTotal/mb)
+		prInt("used swap", st.mem.swapUsed/mb)
+		prInt("free swap", st.mem.swapFree/mb)
+		prInt("swap cached", st.mem.swapCached/mb)
+		sep()
+	}
+
+	for _, z := range st.mem.zram {
+		prStr("zram", z.name)
+		prInt("disk size MB", int(z.diskSize)/mb)
+		prInt("stored MB", int(z.orig)/mb)
+		prInt("compressed MB", int(z.compr)/mb)
+		prInt("mem used MB", int(z.memUsed)/mb)
+		prFloat("ratio", z.ratio)
+		sep()
+	}
+
+	level, info := memPressure(st, vars)
+	prStrL("mem pressure", level+" ("+info+")")
 	sep()
 
-	if vars.wifiClient != nil && st.wifiBss != nil {
-		prStr("wifi iface", vars.wifiIface.Name)
-		prStr("ssid", st.wifiBss.SSID)
-		prInt("wifi signal", st.wifiInfo.Signal)
+	if vars.show.pressure {
+		printPressure(st, vars)
+	}
+
+	for _, t := range st.temps {
+		prStr(t.name+" temp", fmtTempGroup(t, vars))
+	}
+	if len(st.temps) > 0 {
 		sep()
 	}
 
-	if vars.batCapacityFd != nil {
-		prStr("bat level", st.batLevel+"%")
+	for i, gpu := range st.gpus {
+		dev := vars.gpus[i]
+		prStr("gpu", gpu.name)
+		prStr("driver", gpu.driver)
+		if dev.fanFd != nil {
+			prInt("fan RPM", int(gpu.fan))
+		}
+		if dev.powerFd != nil {
+			prFloat("power W", gpu.power)
+		}
+		if dev.busyFd != nil {
+			prInt("busy %", int(gpu.busy))
+		}
+		if dev.vramUsedFd != nil {
+			prInt("vram used MB", int(gpu.vramUsed/(1024*1024)))
+			prInt("vram total MB", int(gpu.vramTotal/(1024*1024)))
+		}
+		sep()
+	}
+
+	var chipDir string
+	for _, s := range st.sensors {
+		if s.dir != chipDir {
+			if chipDir != "" {
+				sep()
+			}
+			prStrL(s.chip, fp.Base(s.dir))
+			chipDir = s.dir
+		}
+		fmt.Printf("  %-16s %s\n", s.label, fmtSensor(s))
+	}
+	if chipDir != "" {
+		sep()
+	}
+
+	for _, n := range st.net {
+		prStr("net iface", n.name)
+		prStr("state", n.state)
+		for _, addr := range n.addrs {
+			prStrL("addr", addr)
+		}
+		prFloat("rx KB/s", n.rxRate/1024)
+		prFloat("tx KB/s", n.txRate/1024)
+		prFloat("rx total MB", float64(n.rxBytes)/(1024*1024))
+		prFloat("tx total MB", float64(n.txBytes)/(1024*1024))
+		sep()
+	}
+
+	for _, w := range st.wifis {
+		printWifi(w, vars)
+		sep()
+	}
+
+	for _, bat := range st.bats {
+		prStr("battery", bat.name)
+		prStr("status", bat.status)
+		prStr("level", strconv.Itoa(bat.level)+"%")
+		if bat.cycles > 0 {
+			prInt("cycle count", bat.cycles)
+		}
+		if bat.wear != 0 {
+			prFloat("wear %", bat.wear)
+		}
+		if bat.chargeStart != "" || bat.chargeEnd != "" {
+			prStr("charge limits", fmtChargeThresholds(bat))
+		}
+		sep()
 	}
 
-	if vars.batPowerFd != nil {
+	if vars.has.bat {
+		prStr("bat level", st.batLevel+"%")
 		prStr("bat time left", st.batTimeLeft)
 	}
 
+	if len(vars.acOnlineFds) > 0 {
+		prStr("ac online", strconv.FormatBool(st.acOnline))
+	}
+
+	if vars.has.bat || len(vars.acOnlineFds) > 0 {
+		sep()
+	}
+
+	if vars.show.vpn {
+		state := "down"
+		if st.vpn.up {
+			state = "up"
+		}
+		prStr("vpn", state)
+		if vars.vpnPidFile != "" {
+			prStr("vpn pid alive", strconv.FormatBool(st.vpn.pidAlive))
+		}
+		if vars.vpnRoute != "" {
+			prStr("vpn route", strconv.FormatBool(st.vpn.routeFound))
+		}
+		if st.vpn.iface != "" {
+			prStr("vpn iface", st.vpn.iface)
+			prFloat("rx KB/s", st.vpn.rxRate/1024)
+			prFloat("tx KB/s", st.vpn.txRate/1024)
+		}
+		sep()
+	}
+
 	for _, p := range st.ps {
-		if !vars.login && p.args[0] == '-' {
+		if hideProcess(p, vars) {
 			continue
 		}
 
@@ -180,16 +407,99 @@ func printAll(st *sttsT, vars *varsT) {
 	}
 }
 
+func printWifi(w wifiT, vars *varsT) {
+	prStr("wifi iface", w.iface)
+
+	if w.connected() {
+		prStr("ssid", w.bss.SSID)
+		prStr("bssid", w.bss.BSSID.String())
+
+		band, channel := wifiBand(w.bss.Frequency)
+		prInt("frequency MHz", w.bss.Frequency)
+		if band != "" {
+			prStr("band", fmt.Sprintf("%s ch %d", band, channel))
+		}
+		if width := getWifiWidth(w.dev, vars); width > 0 {
+			prInt("width MHz", width)
+		}
+	} else {
+		prStr("ssid", "no conn")
+	}
+
+	if w.info != nil {
+		prInt("wifi signal", w.info.Signal)
+		prStr("tx bitrate", fmtBitrate(w.info.TransmitBitrate))
+		prStr("rx bitrate", fmtBitrate(w.info.ReceiveBitrate))
+		prStr("connected", fmtAge(w.info.Connected))
+	}
+
+	if !vars.wifiScan {
+		return
+	}
+
+	for _, bss := range getWifiScan(w.dev, vars) {
+		fmt.Printf("  %-17s %5dMHz %6.1fdBm  %s\n", bss.bssid, bss.freq,
+			bss.signal, bss.ssid)
+	}
+}
+
+func printPressure(st *sttsT, vars *varsT) {
+	p := st.pressure
+
+	if vars.has.psi {
+		psis := []psiT{p.cpu, p.mem, p.io}
+		for i, res := range psiResources {
+			if vars.psiFds[i] == nil {
+				continue
+			}
+			prStrL(res+" some %", fmtPsiAvgs(psis[i].some))
+			prStrL(res+" full %", fmtPsiAvgs(psis[i].full))
+		}
+		sep()
+	}
+
+	if vars.vmstatFd != nil {
+		prFloat("major faults/s", p.majFaultRate)
+		prFloat("swapped in/s", p.swapInRate)
+		prFloat("swapped out/s", p.swapOutRate)
+		prInt("oom kills", int(p.oomKills))
+	}
+	if len(vars.coreThrottleFds) > 0 {
+		prInt("core throttles", int(p.coreThrottle))
+	}
+	if len(vars.pkgThrottleFds) > 0 {
+		prInt("pkg throttles", int(p.pkgThrottle))
+	}
+	if vars.vmstatFd != nil || len(vars.coreThrottleFds) > 0 {
+		sep()
+	}
+}
+
+// fmtPsiAvgs formats the 10s, 60s and 300s averages
+func fmtPsiAvgs(avgs [3]float64) string {
+	return fmt.Sprintf("%.2f %.2f %.2f", avgs[0], avgs[1], avgs[2])
+}
+
 func printDebug(st *sttsT, vars *varsT) {
 	sep()
 	prStr("debug info", "")
 	prStr("==========", "")
 	sep()
 
-	prStr("cpu1 temps", str.Join(st.cpu1Temps, ","))
-	prStr("cpu2 temps", str.Join(st.cpu2Temps, ","))
-	prStr("drive temps", str.Join(st.driveTemps, ","))
-	prStr("mobo temps", str.Join(st.moboTemps, ","))
+	for _, core := range st.cpu.cores {
+		usage := fmt.Sprintf("us %.1f sy %.1f wa %.1f st %.1f",
+			core.user, core.system, core.iowait, core.steal)
+		prStrL(core.name, usage)
+	}
+	prSl("cpu freq files", getFdNames(vars.cpuFreqFds))
+
+	for _, t := range st.temps {
+		var temps []string
+		for _, temp := range t.temps {
+			temps = append(temps, strconv.Itoa(temp))
+		}
+		prStrL(t.name+" temps", str.Join(temps, ","))
+	}
 	sep()
 
 	prStr("cpu1 temp hwmon  ", vars.cpu1TempHwmon)
@@ -198,11 +508,29 @@ func printDebug(st *sttsT, vars *varsT) {
 
 	prSl("drive temp hwmons", vars.driveTempHwmons)
 	prSl("mobo temp hwmons", vars.moboTempHwmons)
+	prSl("gpu hwmons", vars.gpuHwmons)
+	prSl("nvme hwmons", vars.nvmeHwmons)
 	prSl("i2c mobo temp sensors", vars.i2cMoboTemps)
+	for _, chip := range vars.hwmonChips {
+		var files []string
+		for _, sensor := range chip.sensors {
+			files = append(files, sensor.fd.Name())
+		}
+		prSl(chip.name+" sensors", files)
+	}
+
 	prSl("misc hwmon names", vars.miscHwmonNames)
 	prSl("misc i2c names", vars.miscI2cNames)
 }
 
+func getFdNames(fds []*os.File) []string {
+	var names []string
+	for _, fd := range fds {
+		names = append(names, fd.Name())
+	}
+	return names
+}
+
 func prFloat(s string, f float64) {
 	fmt.Printf("%-14s%10.2f\n", s, f)
 }
@@ -6,6 +6,7 @@ import (
 	"os"
 	"sort"
 	"strconv"
+	"syscall"
 	"unicode"
 
 	fp "path/filepath"
@@ -33,11 +34,31 @@ func getProcInfo(st *sttsT, vars *varsT) {
 		p.fdCount, p.files = getProcessFiles(p.pid)
 		p.env = readStringSl(fp.Join(path, "environ"))
 		p.stat = getPsStat(p.pid)
+		p.uid = getProcessUid(path)
 
 		st.ps = append(st.ps, p)
 	}
 }
 
+// hideProcess tells whether a process is a login shell hidden without -l
+func hideProcess(p processT, vars *varsT) bool {
+	return !vars.login && p.args != "" && p.args[0] == '-'
+}
+
+func getProcessUid(path string) int {
+	fileInfo, err := os.Stat(path)
+	if err != nil {
+		return -1
+	}
+
+	stat, ok := fileInfo.Sys().(*syscall.Stat_t)
+	if !ok {
+		return -1
+	}
+
+	return int(stat.Uid)
+}
+
 func getProcessFiles(pid string) (int, []string) {
 	path := fp.Join("/proc", pid, "fd")
 	files, err := os.ReadDir(path)
@@ -88,11 +109,21 @@ func getProcessIo(pid string) (int, int) {
 func getPsStat(pid string) psStatT {
 	var psStat psStatT
 	path := fp.Join("/proc", pid, "stat")
-	fd, err := os.Open(path)
+	statBin, err := os.ReadFile(path)
 	if err != nil {
 		return psStat
 	}
-	defer fd.Close()
+
+	// comm may contain spaces and parentheses, so take everything between
+	// the first '(' and the last ')' and scan the rest with a placeholder
+	stat := string(statBin)
+	commStart := str.IndexByte(stat, '(')
+	commEnd := str.LastIndexByte(stat, ')')
+	if commStart < 0 || commEnd < commStart {
+		return psStat
+	}
+	comm := stat[commStart : commEnd+1]
+	stat = stat[:commStart] + "-" + stat[commEnd+1:]
 
 	f := "%d %s %c %d %d %d %d %d %d %d "
 	f += "%d %d %d %d %d %d %d %d %d %d "
@@ -100,7 +131,7 @@ func getPsStat(pid string) psStatT {
 	f += "%d %d %d %d %d %d %d %d %d %d "
 	f += "%d %d %d %d %d %d %d %d %d %d "
 	f += "%d %d"
-	_, err = fmt.Fscanf(fd, f,
+	_, err = fmt.Sscanf(stat, f,
 		&psStat.pid,
 		&psStat.comm,
 		&psStat.state,
@@ -160,6 +191,8 @@ func getPsStat(pid string) psStatT {
 		&psStat.exit_code,
 	)
 	errExit(err)
+	psStat.comm = comm
+
 	return psStat
 }
 
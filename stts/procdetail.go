@@ -0,0 +1,313 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+// capability names by bit as in linux/capability.h
+var capNames = []string{"chown", "dac_override", "dac_read_search",
+	"fowner", "fsetid", "kill", "setgid", "setuid", "setpcap",
+	"linux_immutable", "net_bind_service", "net_broadcast", "net_admin",
+	"net_raw", "ipc_lock", "ipc_owner", "sys_module", "sys_rawio",
+	"sys_chroot", "sys_ptrace", "sys_pacct", "sys_admin", "sys_boot",
+	"sys_nice", "sys_resource", "sys_time", "sys_tty_config", "mknod",
+	"lease", "audit_write", "audit_control", "setfcap", "mac_override",
+	"mac_admin", "syslog", "wake_alarm", "block_suspend", "audit_read",
+	"perfmon", "bpf", "checkpoint_restore"}
+
+var seccompModes = []string{"disabled", "strict", "filter"}
+
+// printProcDetail prints everything known about one process, with its files
+// and env vars on -f and -e; sections that can't be read, e.g. smaps of
+// another user's process, are left out
+func printProcDetail(pid int, vars *varsT) {
+	pidStr := strconv.Itoa(pid)
+	dir := fp.Join("/proc", pidStr)
+	if !pathIsDir(dir) {
+		errExit(fmt.Errorf("no such process: %d", pid))
+	}
+
+	p := processT{pid: pidStr}
+	p.bin, _ = os.Readlink(fp.Join(dir, "exe"))
+	p.args = readString(fp.Join(dir, "cmdline"))
+	p.pwd, _ = os.Readlink(fp.Join(dir, "cwd"))
+	p.stat = getPsStat(pidStr)
+	p.uid = getProcessUid(dir)
+	p.fdCount, p.files = getProcessFiles(pidStr)
+
+	prStrL("pid", p.pid)
+	prStrL("comm", p.stat.comm)
+	prStrL("bin", p.bin)
+	prStrL("args", p.args)
+	prStrL("pwd", p.pwd)
+	prStrL("state", string(p.stat.state))
+	prIntL("ppid", p.stat.ppid)
+	prStrL("user", getUserName(p.uid))
+	prIntL("threads", p.stat.num_threads)
+	prIntL("fd count", p.fdCount)
+	sep()
+
+	printProcStatus(readProcKv(fp.Join(dir, "status"), ":"))
+	printProcMem(readProcKv(fp.Join(dir, "smaps_rollup"), ":"))
+	printProcLimits(fp.Join(dir, "limits"))
+	printProcSockets(dir, p.files)
+
+	cgroup := getProcCgroup(pidStr)
+	if cgroup != "" {
+		unit, _ := cgroupUnit(cgroup)
+		prStrL("cgroup", cgroup)
+		if unit != "" {
+			prStrL("unit", unit)
+		}
+		sep()
+	}
+
+	printProcNs(dir)
+
+	prStrL("oom score", readSysString(fp.Join(dir, "oom_score")))
+	prStrL("oom score adj", readSysString(fp.Join(dir, "oom_score_adj")))
+
+	if vars.files {
+		sep()
+		header := "files"
+		for _, file := range p.files {
+			prStrL(header, file)
+			header = ""
+		}
+	}
+
+	if vars.env {
+		sep()
+		header := "env vars"
+		for _, env := range readStringSl(fp.Join(dir, "environ")) {
+			prStrL(header, env)
+			header = ""
+		}
+	}
+}
+
+// readProcKv reads a file of key and value lines like /proc/PID/status
+func readProcKv(file, delim string) map[string]string {
+	kv := make(map[string]string)
+
+	fd, err := os.Open(file)
+	if err != nil {
+		return kv
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	for input.Scan() {
+		key, val, found := str.Cut(input.Text(), delim)
+		if found {
+			kv[key] = str.TrimSpace(val)
+		}
+	}
+
+	return kv
+}
+
+func printProcStatus(status map[string]string) {
+	if len(status) == 0 {
+		return
+	}
+
+	// real, effective, saved and filesystem ids
+	prStrL("uids", str.Join(str.Fields(status["Uid"]), " "))
+	prStrL("gids", str.Join(str.Fields(status["Gid"]), " "))
+	if groups := status["Groups"]; groups != "" {
+		prStrL("groups", groups)
+	}
+
+	for _, key := range []string{"VmPeak", "VmSize", "VmRSS", "VmSwap"} {
+		if val, ok := status[key]; ok {
+			prStrL(key, val)
+		}
+	}
+
+	prStrL("cap effective", fmtCaps(status["CapEff"]))
+	prStrL("cap permitted", fmtCaps(status["CapPrm"]))
+	prStrL("cap bounding", fmtCaps(status["CapBnd"]))
+	if status["CapAmb"] != "" && fmtCaps(status["CapAmb"]) != "none" {
+		prStrL("cap ambient", fmtCaps(status["CapAmb"]))
+	}
+
+	if status["NoNewPrivs"] == "1" {
+		prStrL("no new privs", "true")
+	}
+	if mode, err := strconv.Atoi(status["Seccomp"]); err == nil &&
+		mode < len(seccompModes) {
+		seccomp := seccompModes[mode]
+		filters := status["Seccomp_filters"]
+		if mode == 2 && filters != "" {
+			seccomp += " (" + filters + " filters)"
+		}
+		prStrL("seccomp", seccomp)
+	}
+	sep()
+}
+
+// fmtCaps lists the capabilities of a hex capability mask
+func fmtCaps(mask string) string {
+	caps, err := strconv.ParseUint(mask, 16, 64)
+	if err != nil {
+		return mask
+	}
+
+	if caps == 0 {
+		return "none"
+	}
+	if caps == 1<<len(capNames)-1 {
+		return "all"
+	}
+
+	var set, unset []string
+	for bit := 0; bit < 64; bit++ {
+		name := "cap_" + strconv.Itoa(bit)
+		if bit < len(capNames) {
+			name = capNames[bit]
+		}
+
+		switch {
+		case caps&(1<<bit) != 0:
+			set = append(set, name)
+		case bit < len(capNames):
+			unset = append(unset, name)
+		}
+	}
+
+	// root usually has all but a few, which are easier to read
+	if len(set) > len(unset) {
+		return "all except " + str.Join(unset, ",")
+	}
+	return str.Join(set, ",")
+}
+
+// printProcMem prints the proportional and unique set sizes; the uss is the
+// memory only this process maps, which is freed when it exits
+func printProcMem(rollup map[string]string) {
+	if len(rollup) == 0 {
+		return
+	}
+
+	kb := func(key string) int {
+		val, _ := strconv.Atoi(str.TrimSuffix(rollup[key], " kB"))
+		return val
+	}
+
+	prIntL("rss KB", kb("Rss"))
+	prIntL("pss KB", kb("Pss"))
+	prIntL("uss KB", kb("Private_Clean")+kb("Private_Dirty"))
+	prIntL("shared KB", kb("Shared_Clean")+kb("Shared_Dirty"))
+	prIntL("swap KB", kb("Swap"))
+	prIntL("swap pss KB", kb("SwapPss"))
+	sep()
+}
+
+// printProcLimits prints the limits that differ from unlimited
+func printProcLimits(file string) {
+	fd, err := os.Open(file)
+	if err != nil {
+		return
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	input.Scan()
+	header := "limits"
+	for input.Scan() {
+		line := input.Text()
+		if len(line) < 47 {
+			continue
+		}
+
+		// the name can contain spaces, the columns are of fixed width
+		name := str.TrimSpace(line[:26])
+		vals := str.Fields(line[26:])
+		if len(vals) < 2 ||
+			(vals[0] == "unlimited" && vals[1] == "unlimited") {
+			continue
+		}
+
+		limit := fmt.Sprintf("%-22s %s / %s", name, vals[0], vals[1])
+		if len(vals) > 2 {
+			limit += " " + vals[2]
+		}
+		prStrL(header, limit)
+		header = ""
+	}
+	if header == "" {
+		sep()
+	}
+}
+
+// printProcSockets resolves the socket fds of a process against the socket
+// tables of its network namespace
+func printProcSockets(dir string, files []string) {
+	var inodes []uint64
+	for _, file := range files {
+		if inode, ok := getSockInode(file); ok {
+			inodes = append(inodes, inode)
+		}
+	}
+	if len(inodes) == 0 {
+		return
+	}
+
+	socks := make(map[uint64]sockT)
+	for _, s := range readSockets(fp.Join(dir, "net")) {
+		socks[s.inode] = s
+	}
+
+	var out []string
+	for _, inode := range inodes {
+		s, ok := socks[inode]
+		if !ok {
+			// netlink, packet and other families aren't resolved
+			out = append(out, fmt.Sprintf("socket:[%d]", inode))
+			continue
+		}
+		out = append(out, fmtSock(s))
+	}
+	sort.Strings(out)
+
+	header := "sockets"
+	for _, s := range out {
+		prStrL(header, s)
+		header = ""
+	}
+	sep()
+}
+
+// printProcNs prints the namespaces and marks those not shared with init
+func printProcNs(dir string) {
+	entries, err := os.ReadDir(fp.Join(dir, "ns"))
+	if err != nil {
+		return
+	}
+
+	header := "namespaces"
+	for _, entry := range entries {
+		target, err := os.Readlink(fp.Join(dir, "ns", entry.Name()))
+		if err != nil {
+			continue
+		}
+
+		initTarget, _ := os.Readlink(fp.Join("/proc/1/ns", entry.Name()))
+		if initTarget != "" && initTarget != target {
+			target += " (own)"
+		}
+		prStrL(header, fmt.Sprintf("%-18s %s", entry.Name(), target))
+		header = ""
+	}
+	if header == "" {
+		sep()
+	}
+}
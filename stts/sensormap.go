@@ -0,0 +1,216 @@
+package main
+
+import (
+	"fmt"
+	"os"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type tempGroupDefT struct {
+	name  string
+	label string
+	agg   string
+}
+
+type tempMapT struct {
+	group string
+	kind  string
+	val   string
+}
+
+// parseTempGroup reads a temp_group value: name:label[:agg]
+func parseTempGroup(val, line string) tempGroupDefT {
+	fields := str.Split(val, ":")
+	if len(fields) < 2 || len(fields) > 3 || fields[0] == "" {
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+
+	def := tempGroupDefT{name: fields[0], label: fields[1]}
+	if len(fields) == 3 {
+		def.agg = getTempAgg(fields[2], line)
+	}
+
+	return def
+}
+
+// parseTempMap reads a temp_map value: group:kind=value, where kind is one
+// of hwmon, label, i2c or path
+func parseTempMap(val, line string) tempMapT {
+	group, sensor, found := str.Cut(val, ":")
+	if !found || group == "" {
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+
+	kind, sensorVal, found := str.Cut(sensor, "=")
+	if !found || sensorVal == "" {
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+
+	switch kind {
+	case "hwmon", "label", "i2c", "path":
+	default:
+		errExit(fmt.Errorf("incorrect config line: %s", line))
+	}
+
+	return tempMapT{group: group, kind: kind, val: sensorVal}
+}
+
+// applyTempMaps moves the sensors matched by temp_map lines into their
+// groups; a built-in group targeted by a map keeps only the mapped sensors
+// and a mapped sensor is removed from the built-in group it was detected in
+func applyTempMaps(vars *varsT) {
+	if len(vars.tempMaps) == 0 && len(vars.tempGroupDefs) == 0 {
+		return
+	}
+
+	mapped := make(map[string][]string)
+	var claimed []string
+	for _, m := range vars.tempMaps {
+		files := resolveTempMap(m)
+		mapped[m.group] = append(mapped[m.group], files...)
+		claimed = append(claimed, files...)
+	}
+
+	var groups []tempGroupT
+	for _, group := range vars.tempGroups {
+		_, targeted := mapped[group.name]
+
+		var fds []*os.File
+		for _, fd := range group.fds {
+			if targeted || elInSlice(claimed, fd.Name()) {
+				fd.Close()
+				continue
+			}
+			fds = append(fds, fd)
+		}
+		group.fds = fds
+
+		groups = append(groups, group)
+	}
+	vars.tempGroups = groups
+
+	for _, def := range vars.tempGroupDefs {
+		if getTempGroup(vars, def.name) == nil {
+			vars.tempGroups = append(vars.tempGroups,
+				tempGroupT{name: def.name})
+		}
+	}
+
+	for _, m := range vars.tempMaps {
+		if getTempGroup(vars, m.group) == nil {
+			vars.tempGroups = append(vars.tempGroups,
+				tempGroupT{name: m.group})
+		}
+	}
+
+	for i := range vars.tempGroups {
+		group := &vars.tempGroups[i]
+		setTempGroupDef(vars, group)
+
+		for _, file := range mapped[group.name] {
+			fd, err := os.Open(file)
+			if err != nil {
+				continue
+			}
+			group.fds = append(group.fds, fd)
+		}
+	}
+
+	groups = vars.tempGroups[:0]
+	for _, group := range vars.tempGroups {
+		if len(group.fds) > 0 {
+			groups = append(groups, group)
+		}
+	}
+	vars.tempGroups = groups
+}
+
+func getTempGroup(vars *varsT, name string) *tempGroupT {
+	for i := range vars.tempGroups {
+		if vars.tempGroups[i].name == name {
+			return &vars.tempGroups[i]
+		}
+	}
+	return nil
+}
+
+func setTempGroupDef(vars *varsT, group *tempGroupT) {
+	if group.label == "" {
+		group.label = group.name
+	}
+	if group.agg == "" {
+		group.agg = vars.tempAggDefault
+	}
+
+	for _, def := range vars.tempGroupDefs {
+		if def.name != group.name {
+			continue
+		}
+		group.label = def.label
+		if def.agg != "" {
+			group.agg = def.agg
+		}
+	}
+}
+
+// resolveTempMap returns the paths of temperature inputs matched by a map
+func resolveTempMap(m tempMapT) []string {
+	var files []string
+
+	switch m.kind {
+	case "hwmon":
+		chip, label, _ := str.Cut(m.val, "/")
+		dirs, _ := fp.Glob("/sys/class/hwmon/hwmon*")
+		for _, dir := range dirs {
+			if readSysString(fp.Join(dir, "name")) != chip {
+				continue
+			}
+			files = append(files, getTempInputs(dir, label)...)
+		}
+	case "label":
+		dirs, _ := fp.Glob("/sys/class/hwmon/hwmon*")
+		for _, dir := range dirs {
+			files = append(files, getTempInputs(dir, m.val)...)
+		}
+	case "i2c":
+		dirs, _ := fp.Glob("/sys/bus/i2c/devices/*")
+		for _, dir := range dirs {
+			if readSysString(fp.Join(dir, "name")) != m.val {
+				continue
+			}
+
+			// older drivers put the inputs right in the device dir,
+			// newer ones in a hwmon subdir
+			files = append(files, getTempInputs(dir, "")...)
+			hwmonDirs, _ := fp.Glob(fp.Join(dir, "hwmon/hwmon*"))
+			for _, hwmonDir := range hwmonDirs {
+				files = append(files, getTempInputs(hwmonDir, "")...)
+			}
+		}
+	case "path":
+		files, _ = fp.Glob(m.val)
+	}
+
+	return files
+}
+
+// getTempInputs returns temp*_input files of a hwmon dir, only the one with
+// a matching temp*_label when label isn't empty
+func getTempInputs(dir, label string) []string {
+	var files []string
+
+	inputs, _ := fp.Glob(fp.Join(dir, "temp*_input"))
+	for _, input := range inputs {
+		if label != "" {
+			labelFile := str.TrimSuffix(input, "_input") + "_label"
+			if readSysString(labelFile) != label {
+				continue
+			}
+		}
+		files = append(files, input)
+	}
+
+	return files
+}
@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"regexp"
+	"sort"
+	"strconv"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type hwmonChipT struct {
+	name    string
+	dir     string
+	sensors []hwmonSensorT
+}
+
+type hwmonSensorT struct {
+	kind  string
+	index int
+	label string
+	fd    *os.File
+
+	// limits in display units, zero when the chip doesn't provide them
+	crit float64
+	max  float64
+}
+
+type sensorT struct {
+	chip  string
+	dir   string
+	kind  string
+	label string
+	value float64
+	crit  float64
+	max   float64
+}
+
+// sensor kinds in display order, with the divisor from sysfs units and the
+// display unit
+var sensorKinds = []struct {
+	kind string
+	div  float64
+	unit string
+}{
+	{"temp", 1000, "°C"},
+	{"fan", 1, "RPM"},
+	{"in", 1000, "V"},
+	{"curr", 1000, "A"},
+	{"power", 1000000, "W"},
+}
+
+var sensorInputRe = regexp.MustCompile(
+	`^(temp|fan|in|curr|power)([0-9]+)_(input|average)$`)
+
+func detectSensors(vars *varsT) {
+	hwmonDirs, err := os.ReadDir("/sys/class/hwmon")
+	if err != nil {
+		return
+	}
+
+	for _, dir := range hwmonDirs {
+		chipDir := fp.Join("/sys/class/hwmon", dir.Name())
+
+		nameBin, err := ioutil.ReadFile(fp.Join(chipDir, "name"))
+		if err != nil {
+			continue
+		}
+
+		chip := hwmonChipT{
+			name: str.TrimSpace(string(nameBin)),
+			dir:  chipDir,
+		}
+		chip.sensors = detectChipSensors(chipDir)
+
+		vars.hwmonChips = append(vars.hwmonChips, chip)
+	}
+
+	sort.Slice(vars.hwmonChips, func(i, j int) bool {
+		return hwmonIndex(vars.hwmonChips[i].dir) <
+			hwmonIndex(vars.hwmonChips[j].dir)
+	})
+}
+
+func detectChipSensors(chipDir string) []hwmonSensorT {
+	var sensors []hwmonSensorT
+
+	files, err := os.ReadDir(chipDir)
+	if err != nil {
+		return sensors
+	}
+
+	for _, file := range files {
+		match := sensorInputRe.FindStringSubmatch(file.Name())
+		if match == nil {
+			continue
+		}
+
+		kind := match[1]
+		prefix := kind + match[2]
+
+		// amdgpu only has power1_average, prefer _input when both exist
+		if match[3] == "average" &&
+			fileExists(fp.Join(chipDir, prefix+"_input")) {
+			continue
+		}
+
+		fd, err := os.Open(fp.Join(chipDir, file.Name()))
+		if err != nil {
+			continue
+		}
+
+		sensor := hwmonSensorT{kind: kind, fd: fd}
+		sensor.index, _ = strconv.Atoi(match[2])
+
+		sensor.label = readSysString(fp.Join(chipDir, prefix+"_label"))
+		if sensor.label == "" {
+			sensor.label = prefix
+		}
+
+		div := sensorDiv(kind)
+		sensor.crit = readSysFloat(fp.Join(chipDir, prefix+"_crit")) / div
+		sensor.max = readSysFloat(fp.Join(chipDir, prefix+"_max")) / div
+
+		sensors = append(sensors, sensor)
+	}
+
+	sort.Slice(sensors, func(i, j int) bool {
+		ki := sensorKindOrder(sensors[i].kind)
+		kj := sensorKindOrder(sensors[j].kind)
+		if ki != kj {
+			return ki < kj
+		}
+		return sensors[i].index < sensors[j].index
+	})
+
+	return sensors
+}
+
+func getSensorInfo(st *sttsT, vars *varsT) {
+	st.sensors = st.sensors[:0]
+
+	for _, chip := range vars.hwmonChips {
+		for _, sensor := range chip.sensors {
+			val, err := readSysInt(sensor.fd, vars)
+			if err != nil {
+				continue
+			}
+
+			st.sensors = append(st.sensors, sensorT{
+				chip:  chip.name,
+				dir:   chip.dir,
+				kind:  sensor.kind,
+				label: sensor.label,
+				value: float64(val) / sensorDiv(sensor.kind),
+				crit:  sensor.crit,
+				max:   sensor.max,
+			})
+		}
+	}
+}
+
+// readSysInt reads a whole integer sysfs attribute, e.g. a temperature in
+// millidegrees which can be negative or have more than two digits
+func readSysInt(fd *os.File, vars *varsT) (int64, error) {
+	rd := bufio.NewReaderSize(fd, 24)
+	lineBin, _, err := rd.ReadLine()
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		fd.Seek(0, 0)
+	}
+
+	if err != nil {
+		return 0, err
+	}
+
+	return strconv.ParseInt(str.TrimSpace(string(lineBin)), 10, 64)
+}
+
+func readSysString(file string) string {
+	bin, err := ioutil.ReadFile(file)
+	if err != nil {
+		return ""
+	}
+	return str.TrimSpace(string(bin))
+}
+
+func readSysFloat(file string) float64 {
+	val, err := strconv.ParseFloat(readSysString(file), 64)
+	if err != nil {
+		return 0
+	}
+	return val
+}
+
+func sensorDiv(kind string) float64 {
+	for _, k := range sensorKinds {
+		if k.kind == kind {
+			return k.div
+		}
+	}
+	return 1
+}
+
+func sensorUnit(kind string) string {
+	for _, k := range sensorKinds {
+		if k.kind == kind {
+			return k.unit
+		}
+	}
+	return ""
+}
+
+func sensorKindOrder(kind string) int {
+	for i, k := range sensorKinds {
+		if k.kind == kind {
+			return i
+		}
+	}
+	return len(sensorKinds)
+}
+
+func hwmonIndex(dir string) int {
+	id, _ := strconv.Atoi(str.TrimPrefix(fp.Base(dir), "hwmon"))
+	return id
+}
+
+func fmtSensor(s sensorT) string {
+	var val string
+	switch s.kind {
+	case "fan":
+		val = fmt.Sprintf("%.0f%s", s.value, sensorUnit(s.kind))
+	case "temp":
+		val = fmt.Sprintf("%.1f%s", s.value, sensorUnit(s.kind))
+	default:
+		val = fmt.Sprintf("%.2f%s", s.value, sensorUnit(s.kind))
+	}
+
+	var limits []string
+	if s.max != 0 {
+		limits = append(limits, fmt.Sprintf("max %g", s.max))
+	}
+	if s.crit != 0 {
+		limits = append(limits, fmt.Sprintf("crit %g", s.crit))
+	}
+	if len(limits) > 0 {
+		val += " (" + str.Join(limits, ", ") + ")"
+	}
+
+	return val
+}
@@ -0,0 +1,414 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+const metricsContentType = "application/openmetrics-text; " +
+	"version=1.0.0; charset=utf-8"
+
+type metricsT struct {
+	sb   str.Builder
+	last string
+}
+
+func serveMetrics(addr string, st *sttsT, vars *varsT) {
+	// the fds opened in getVars are shared, so scrapes must not overlap
+	var mu sync.Mutex
+
+	http.HandleFunc("/metrics", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		getAllInfo(st, vars)
+		st.ps = nil
+		getProcInfo(st, vars)
+		out := getMetrics(st, vars)
+		mu.Unlock()
+
+		w.Header().Set("Content-Type", metricsContentType)
+		fmt.Fprint(w, out)
+	})
+
+	// the json export without processes, polled by -collect
+	http.HandleFunc("/snapshot", func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		getAllInfo(st, vars)
+		st.ps = nil
+		out, err := json.Marshal(getExport(st, vars))
+		mu.Unlock()
+
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		fmt.Fprintf(w, "%s\n", out)
+	})
+
+	err := http.ListenAndServe(addr, nil)
+	errExit(err)
+}
+
+func getMetrics(st *sttsT, vars *varsT) string {
+	var m metricsT
+
+	m.add("stts_uptime_seconds", "gauge", "",
+		st.uptime.Seconds())
+
+	m.add("stts_load", "gauge", `period="1m"`, st.loads[0])
+	m.add("stts_load", "gauge", `period="5m"`, st.loads[1])
+	m.add("stts_load", "gauge", `period="15m"`, st.loads[2])
+
+	m.add("stts_processes", "gauge", "", float64(st.procs))
+
+	if vars.has.cpuUsage {
+		cpus := append([]cpuUsageT{st.cpu.total}, st.cpu.cores...)
+		for _, c := range cpus {
+			addCpuUsage(&m, c)
+		}
+		for _, c := range st.cpu.cores {
+			if freq, ok := st.cpu.freqs[c.name]; ok {
+				m.add("stts_cpu_frequency_mhz", "gauge",
+					label("cpu", c.name), float64(freq))
+			}
+		}
+	}
+
+	memTypes := []struct {
+		name string
+		val  int
+	}{
+		{"total", st.mem.total},
+		{"used", st.mem.used},
+		{"free", st.mem.free},
+		{"shared", st.mem.shared},
+		{"buffer", st.mem.buffer},
+		{"cache", st.mem.cache},
+		{"available", st.mem.avail},
+		{"hugepages", st.mem.huge},
+		{"dirty", st.mem.dirty},
+		{"writeback", st.mem.writeback},
+		{"anon_hugepages", st.mem.anonHuge},
+	}
+	for _, mt := range memTypes {
+		m.add("stts_memory_bytes", "gauge",
+			label("type", mt.name), float64(mt.val))
+	}
+
+	swapTypes := []struct {
+		name string
+		val  int
+	}{
+		{"total", st.mem.swapTotal},
+		{"used", st.mem.swapUsed},
+		{"free", st.mem.swapFree},
+		{"cached", st.mem.swapCached},
+	}
+	for _, mt := range swapTypes {
+		m.add("stts_swap_bytes", "gauge",
+			label("type", mt.name), float64(mt.val))
+	}
+
+	for _, z := range st.mem.zram {
+		m.add("stts_zram_original_bytes", "gauge",
+			label("device", z.name), float64(z.orig))
+	}
+	for _, z := range st.mem.zram {
+		m.add("stts_zram_compressed_bytes", "gauge",
+			label("device", z.name), float64(z.compr))
+	}
+	for _, z := range st.mem.zram {
+		m.add("stts_zram_used_bytes", "gauge",
+			label("device", z.name), float64(z.memUsed))
+	}
+
+	m.add("stts_root_disk_free_bytes", "gauge", "",
+		st.rootDiskFree*1024*1024)
+
+	for _, d := range st.disks {
+		m.add("stts_filesystem_size_bytes", "gauge",
+			label("mount", d.path), float64(d.total))
+	}
+	for _, d := range st.disks {
+		m.add("stts_filesystem_free_bytes", "gauge",
+			label("mount", d.path), float64(d.free))
+	}
+	for _, d := range st.disks {
+		m.add("stts_filesystem_inodes", "gauge",
+			label("mount", d.path), float64(d.inodes))
+	}
+	for _, d := range st.disks {
+		m.add("stts_filesystem_inodes_free", "gauge",
+			label("mount", d.path), float64(d.inodesFree))
+	}
+
+	for _, t := range st.temps {
+		for i, r := range t.temps {
+			labels := label("group", t.name) + "," +
+				label("sensor", strconv.Itoa(i))
+			m.add("stts_temperature_celsius", "gauge", labels,
+				float64(r)/1000)
+		}
+	}
+
+	for i, g := range st.gpus {
+		if vars.gpus[i].fanFd != nil {
+			m.add("stts_gpu_fan_rpm", "gauge", label("gpu", g.name),
+				float64(g.fan))
+		}
+	}
+	for i, g := range st.gpus {
+		if vars.gpus[i].powerFd != nil {
+			m.add("stts_gpu_power_watts", "gauge", label("gpu", g.name),
+				g.power)
+		}
+	}
+	for i, g := range st.gpus {
+		if vars.gpus[i].busyFd != nil {
+			m.add("stts_gpu_busy_percent", "gauge", label("gpu", g.name),
+				float64(g.busy))
+		}
+	}
+	for i, g := range st.gpus {
+		if vars.gpus[i].vramUsedFd != nil {
+			m.add("stts_gpu_vram_used_bytes", "gauge",
+				label("gpu", g.name), float64(g.vramUsed))
+		}
+	}
+	for i, g := range st.gpus {
+		if vars.gpus[i].vramUsedFd != nil {
+			m.add("stts_gpu_vram_total_bytes", "gauge",
+				label("gpu", g.name), float64(g.vramTotal))
+		}
+	}
+
+	for _, n := range st.net {
+		m.add("stts_network_receive_bytes", "counter",
+			label("iface", n.name), float64(n.rxBytes))
+	}
+	for _, n := range st.net {
+		m.add("stts_network_transmit_bytes", "counter",
+			label("iface", n.name), float64(n.txBytes))
+	}
+	for _, n := range st.net {
+		var up float64
+		if n.state == "up" {
+			up = 1
+		}
+		m.add("stts_network_up", "gauge", label("iface", n.name), up)
+	}
+
+	sensorMetrics := map[string]string{
+		"temp":  "stts_hwmon_temperature_celsius",
+		"fan":   "stts_hwmon_fan_rpm",
+		"in":    "stts_hwmon_voltage_volts",
+		"curr":  "stts_hwmon_current_amperes",
+		"power": "stts_hwmon_power_watts",
+	}
+	for _, k := range sensorKinds {
+		for _, s := range st.sensors {
+			if s.kind != k.kind {
+				continue
+			}
+			labels := label("chip", s.chip) + "," +
+				label("hwmon", fp.Base(s.dir)) + "," +
+				label("sensor", s.label)
+			m.add(sensorMetrics[k.kind], "gauge", labels, s.value)
+		}
+	}
+
+	for _, w := range st.wifis {
+		if w.info == nil {
+			continue
+		}
+		labels := label("iface", w.iface)
+		if w.bss != nil {
+			labels += "," + label("ssid", w.bss.SSID)
+		}
+		m.add("stts_wifi_signal_dbm", "gauge", labels,
+			float64(w.info.Signal))
+	}
+	for _, w := range st.wifis {
+		if w.info == nil {
+			continue
+		}
+		m.add("stts_wifi_receive_bitrate_bps", "gauge",
+			label("iface", w.iface), float64(w.info.ReceiveBitrate))
+	}
+	for _, w := range st.wifis {
+		if w.info == nil {
+			continue
+		}
+		m.add("stts_wifi_transmit_bitrate_bps", "gauge",
+			label("iface", w.iface), float64(w.info.TransmitBitrate))
+	}
+
+	if vars.has.bat {
+		batLevel, _ := strconv.ParseFloat(st.batLevel, 64)
+		m.add("stts_battery_level_percent", "gauge", "", batLevel)
+		m.add("stts_battery_time_left_seconds", "gauge", "",
+			float64(st.batMinLeft*60))
+
+		for _, bat := range st.bats {
+			m.add("stts_battery_device_level_percent", "gauge",
+				label("battery", bat.name), float64(bat.level))
+		}
+		for _, bat := range st.bats {
+			m.add("stts_battery_cycle_count", "gauge",
+				label("battery", bat.name), float64(bat.cycles))
+		}
+		for _, bat := range st.bats {
+			m.add("stts_battery_wear_percent", "gauge",
+				label("battery", bat.name), bat.wear)
+		}
+	}
+
+	if vars.show.vpn {
+		var up float64
+		if st.vpn.up {
+			up = 1
+		}
+		m.add("stts_vpn_up", "gauge", "", up)
+	}
+
+	if vars.show.pressure {
+		addPressureMetrics(&m, st.pressure, vars)
+	}
+
+	if len(vars.acOnlineFds) > 0 {
+		var online float64
+		if st.acOnline {
+			online = 1
+		}
+		m.add("stts_ac_online", "gauge", "", online)
+	}
+
+	for _, p := range st.ps {
+		m.add("stts_process_read_bytes", "counter", procLabels(p),
+			float64(p.readBytes))
+	}
+	for _, p := range st.ps {
+		m.add("stts_process_written_bytes", "counter", procLabels(p),
+			float64(p.writeBytes))
+	}
+	for _, p := range st.ps {
+		m.add("stts_process_open_fds", "gauge", procLabels(p),
+			float64(p.fdCount))
+	}
+
+	m.sb.WriteString("# EOF\n")
+
+	return m.sb.String()
+}
+
+func addCpuUsage(m *metricsT, c cpuUsageT) {
+	modes := []struct {
+		name string
+		val  float64
+	}{
+		{"user", c.user},
+		{"system", c.system},
+		{"iowait", c.iowait},
+		{"steal", c.steal},
+	}
+	for _, mode := range modes {
+		labels := label("cpu", c.name) + "," + label("mode", mode.name)
+		m.add("stts_cpu_usage_percent", "gauge", labels, mode.val)
+	}
+}
+
+func addPressureMetrics(m *metricsT, p pressureT, vars *varsT) {
+	psis := []psiT{p.cpu, p.mem, p.io}
+	windows := []string{"10s", "60s", "300s"}
+
+	for i, res := range psiResources {
+		if vars.psiFds[i] == nil {
+			continue
+		}
+		for w, window := range windows {
+			labels := label("resource", res) + "," + label("window", window)
+			m.add("stts_pressure_some_percent", "gauge", labels,
+				psis[i].some[w])
+		}
+	}
+	for i, res := range psiResources {
+		if vars.psiFds[i] == nil {
+			continue
+		}
+		for w, window := range windows {
+			labels := label("resource", res) + "," + label("window", window)
+			m.add("stts_pressure_full_percent", "gauge", labels,
+				psis[i].full[w])
+		}
+	}
+	for i, res := range psiResources {
+		if vars.psiFds[i] == nil {
+			continue
+		}
+		m.add("stts_pressure_some_stalled_seconds", "counter",
+			label("resource", res), float64(psis[i].someTotal)/1e6)
+	}
+	for i, res := range psiResources {
+		if vars.psiFds[i] == nil {
+			continue
+		}
+		m.add("stts_pressure_full_stalled_seconds", "counter",
+			label("resource", res), float64(psis[i].fullTotal)/1e6)
+	}
+
+	if vars.vmstatFd != nil {
+		m.add("stts_major_page_faults", "counter", "",
+			float64(p.majFaults))
+		m.add("stts_oom_kills", "counter", "", float64(p.oomKills))
+		m.add("stts_swapped_in_pages", "counter", "", float64(p.swapIn))
+		m.add("stts_swapped_out_pages", "counter", "", float64(p.swapOut))
+	}
+
+	if len(vars.coreThrottleFds) > 0 {
+		m.add("stts_cpu_core_throttles", "counter", "",
+			float64(p.coreThrottle))
+	}
+	if len(vars.pkgThrottleFds) > 0 {
+		m.add("stts_cpu_package_throttles", "counter", "",
+			float64(p.pkgThrottle))
+	}
+}
+
+// add writes a sample and, for the first sample of a metric family, its
+// type line; samples of one family have to be added one after another
+func (m *metricsT) add(name, kind, labels string, val float64) {
+	if name != m.last {
+		fmt.Fprintf(&m.sb, "# TYPE %s %s\n", name, kind)
+		m.last = name
+	}
+
+	sample := name
+	if kind == "counter" {
+		sample += "_total"
+	}
+	if labels != "" {
+		sample += "{" + labels + "}"
+	}
+
+	fmt.Fprintf(&m.sb, "%s %s\n", sample,
+		strconv.FormatFloat(val, 'f', -1, 64))
+}
+
+func procLabels(p processT) string {
+	return label("pid", p.pid) + "," +
+		label("comm", str.Trim(p.stat.comm, "()"))
+}
+
+func label(name, val string) string {
+	val = str.ReplaceAll(val, `\`, `\\`)
+	val = str.ReplaceAll(val, `"`, `\"`)
+	val = str.ReplaceAll(val, "\n", `\n`)
+	return name + `="` + val + `"`
+}
@@ -0,0 +1,41 @@
+package main
+
+import "testing"
+
+func TestLabel(t *testing.T) {
+	tests := []struct {
+		name, val string
+		want      string
+	}{
+		{"iface", "eth0", `iface="eth0"`},
+		{"mount", `C:\data`, `mount="C:\\data"`},
+		{"comm", `say "hi"`, `comm="say \"hi\""`},
+		{"sensor", "a\nb", `sensor="a\nb"`},
+		{"ssid", `\"` + "\n", `ssid="\\\"\n"`},
+		{"ssid", "", `ssid=""`},
+	}
+
+	for _, tt := range tests {
+		got := label(tt.name, tt.val)
+		if got != tt.want {
+			t.Errorf("label(%q, %q) = %s, want %s", tt.name, tt.val, got,
+				tt.want)
+		}
+	}
+}
+
+func TestMetricsAdd(t *testing.T) {
+	var m metricsT
+	m.add("stts_load", "gauge", `period="1m"`, 0.5)
+	m.add("stts_load", "gauge", `period="5m"`, 1)
+	m.add("stts_oom_kills", "counter", "", 3)
+
+	want := "# TYPE stts_load gauge\n" +
+		"stts_load{period=\"1m\"} 0.5\n" +
+		"stts_load{period=\"5m\"} 1\n" +
+		"# TYPE stts_oom_kills counter\n" +
+		"stts_oom_kills_total 3\n"
+	if got := m.sb.String(); got != want {
+		t.Errorf("metrics =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,179 @@
+package main
+
+import (
+	"bufio"
+	"encoding/hex"
+	"net"
+	"os"
+	"strconv"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+type sockT struct {
+	// tcp, tcp6, udp, udp6 or unix
+	proto string
+	state string
+	inode uint64
+	uid   int
+
+	// ip:port, or the socket path for unix sockets
+	local  string
+	remote string
+}
+
+var tcpStates = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+// readSockets reads the sockets of a network namespace from the net
+// directory of a process, e.g. /proc/1234/net, or /proc/net for the own one
+func readSockets(netDir string) []sockT {
+	var socks []sockT
+
+	for _, proto := range []string{"tcp", "tcp6", "udp", "udp6"} {
+		socks = append(socks, readInetSockets(netDir, proto)...)
+	}
+
+	return append(socks, readUnixSockets(netDir)...)
+}
+
+// readInetSockets parses lines such as
+// 0: 0100007F:0277 00000000:0000 0A 00000000:00000000 00:00000000 ...
+func readInetSockets(netDir, proto string) []sockT {
+	var socks []sockT
+
+	fd, err := os.Open(fp.Join(netDir, proto))
+	if err != nil {
+		return socks
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	input.Scan()
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) < 10 {
+			continue
+		}
+
+		s := sockT{
+			proto:  proto,
+			local:  parseSockAddr(fields[1]),
+			remote: parseSockAddr(fields[2]),
+			state:  tcpStates[fields[3]],
+		}
+		s.uid, _ = strconv.Atoi(fields[7])
+		s.inode, _ = strconv.ParseUint(fields[9], 10, 64)
+
+		// udp only uses established for connected sockets and close for
+		// the rest
+		if str.HasPrefix(proto, "udp") && s.state == "CLOSE" {
+			s.state = "UNCONN"
+		}
+
+		socks = append(socks, s)
+	}
+
+	return socks
+}
+
+// parseSockAddr turns an address like 0100007F:0277 into 127.0.0.1:631;
+// the ip is hex in host byte order per 32 bit word, assumed little-endian
+func parseSockAddr(addr string) string {
+	ipHex, portHex, found := str.Cut(addr, ":")
+	if !found {
+		return addr
+	}
+
+	ip, err := hex.DecodeString(ipHex)
+	if err != nil || (len(ip) != 4 && len(ip) != 16) {
+		return addr
+	}
+	for i := 0; i < len(ip); i += 4 {
+		ip[i], ip[i+1], ip[i+2], ip[i+3] = ip[i+3], ip[i+2], ip[i+1], ip[i]
+	}
+
+	port, err := strconv.ParseUint(portHex, 16, 16)
+	if err != nil {
+		return addr
+	}
+
+	return net.JoinHostPort(net.IP(ip).String(), strconv.Itoa(int(port)))
+}
+
+// readUnixSockets parses lines such as
+// 0000000000000000: 00000002 00000000 00010000 0001 01 20842 /run/foo.sock
+func readUnixSockets(netDir string) []sockT {
+	var socks []sockT
+
+	fd, err := os.Open(fp.Join(netDir, "unix"))
+	if err != nil {
+		return socks
+	}
+	defer fd.Close()
+
+	input := bufio.NewScanner(fd)
+	input.Scan()
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) < 7 {
+			continue
+		}
+
+		s := sockT{proto: "unix", uid: -1}
+		s.inode, _ = strconv.ParseUint(fields[6], 10, 64)
+		if len(fields) > 7 {
+			s.local = fields[7]
+		}
+
+		// __SO_ACCEPTCON is set in the flags of listening sockets
+		flags, _ := strconv.ParseUint(fields[3], 16, 32)
+		switch {
+		case flags&0x10000 != 0:
+			s.state = "LISTEN"
+		case fields[5] == "03":
+			s.state = "CONNECTED"
+		default:
+			s.state = "UNCONN"
+		}
+
+		socks = append(socks, s)
+	}
+
+	return socks
+}
+
+// getSockInode returns the inode of an fd link target like socket:[1234]
+func getSockInode(target string) (uint64, bool) {
+	if !str.HasPrefix(target, "socket:[") || !str.HasSuffix(target, "]") {
+		return 0, false
+	}
+
+	inode := str.TrimSuffix(str.TrimPrefix(target, "socket:["), "]")
+	n, err := strconv.ParseUint(inode, 10, 64)
+	return n, err == nil
+}
+
+func fmtSock(s sockT) string {
+	out := s.proto + " " + s.state
+	if s.local != "" {
+		out += " " + s.local
+	}
+	if s.remote != "" && s.state != "LISTEN" && s.state != "UNCONN" {
+		out += " -> " + s.remote
+	}
+	return out
+}
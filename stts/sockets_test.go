@@ -0,0 +1,133 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseSockAddr(t *testing.T) {
+	tests := []struct {
+		in, want string
+	}{
+		{"0100007F:0277", "127.0.0.1:631"},
+		{"00000000:0016", "0.0.0.0:22"},
+		{"0101A8C0:D431", "192.168.1.1:54321"},
+		{"00000000000000000000000001000000:0016", "[::1]:22"},
+		{"00000000000000000000000000000000:0035", "[::]:53"},
+		{"000080FE00000000FF025002EF5102FE:0202",
+			"[fe80::250:2ff:fe02:51ef]:514"},
+		{"0100007F", "0100007F"},
+		{"0100007:0277", "0100007:0277"},
+		{"0100007F:XYZ", "0100007F:XYZ"},
+		{"01007F:0277", "01007F:0277"},
+	}
+
+	for _, tt := range tests {
+		if got := parseSockAddr(tt.in); got != tt.want {
+			t.Errorf("parseSockAddr(%q) = %q, want %q", tt.in, got, tt.want)
+		}
+	}
+}
+
+func writeNetFile(t *testing.T, dir, name, content string) {
+	err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadInetSockets(t *testing.T) {
+	dir := t.TempDir()
+	writeNetFile(t, dir, "tcp", "  sl  local_address rem_address   st "+
+		"tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"+
+		"   0: 0100007F:0277 00000000:0000 0A 00000000:00000000 "+
+		"00:00000000 00000000     0        0 20842 1 0 100 0 0 10 0\n"+
+		"   1: 0101A8C0:D431 0201A8C0:01BB 01 00000000:00000000 "+
+		"00:00000000 00000000  1000        0 31337 1 0 20 4 30 10 -1\n"+
+		"   2: short line\n")
+	writeNetFile(t, dir, "udp", "   sl  local_address rem_address   st "+
+		"tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode\n"+
+		"  10: 00000000:0044 00000000:0000 07 00000000:00000000 "+
+		"00:00000000 00000000     0        0 1234 2 0 0\n")
+
+	want := []sockT{
+		{proto: "tcp", state: "LISTEN", inode: 20842, uid: 0,
+			local: "127.0.0.1:631", remote: "0.0.0.0:0"},
+		{proto: "tcp", state: "ESTABLISHED", inode: 31337, uid: 1000,
+			local: "192.168.1.1:54321", remote: "192.168.1.2:443"},
+		{proto: "udp", state: "UNCONN", inode: 1234, uid: 0,
+			local: "0.0.0.0:68", remote: "0.0.0.0:0"},
+	}
+
+	got := append(readInetSockets(dir, "tcp"), readInetSockets(dir, "udp")...)
+	if len(got) != len(want) {
+		t.Fatalf("readInetSockets() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("readInetSockets()[%d] = %+v, want %+v", i, got[i],
+				want[i])
+		}
+	}
+
+	if socks := readInetSockets(dir, "tcp6"); len(socks) != 0 {
+		t.Errorf("readInetSockets() of a missing file = %+v", socks)
+	}
+}
+
+func TestReadUnixSockets(t *testing.T) {
+	dir := t.TempDir()
+	writeNetFile(t, dir, "unix", "Num       RefCount Protocol Flags    "+
+		"Type St Inode Path\n"+
+		"0000000000000000: 00000002 00000000 00010000 0001 01 20842 "+
+		"/run/foo.sock\n"+
+		"0000000000000000: 00000003 00000000 00000000 0001 03 20843 "+
+		"/run/foo.sock\n"+
+		"0000000000000000: 00000003 00000000 00000000 0001 03 20844\n"+
+		"0000000000000000: 00000002 00000000 00000000 0002 01 20845\n"+
+		"0000000000000000: 00000002\n")
+
+	want := []sockT{
+		{proto: "unix", state: "LISTEN", inode: 20842, uid: -1,
+			local: "/run/foo.sock"},
+		{proto: "unix", state: "CONNECTED", inode: 20843, uid: -1,
+			local: "/run/foo.sock"},
+		{proto: "unix", state: "CONNECTED", inode: 20844, uid: -1},
+		{proto: "unix", state: "UNCONN", inode: 20845, uid: -1},
+	}
+
+	got := readUnixSockets(dir)
+	if len(got) != len(want) {
+		t.Fatalf("readUnixSockets() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("readUnixSockets()[%d] = %+v, want %+v", i, got[i],
+				want[i])
+		}
+	}
+}
+
+func TestGetSockInode(t *testing.T) {
+	tests := []struct {
+		in    string
+		inode uint64
+		ok    bool
+	}{
+		{"socket:[20842]", 20842, true},
+		{"socket:[]", 0, false},
+		{"socket:[12x]", 0, false},
+		{"socket:[123", 0, false},
+		{"pipe:[20842]", 0, false},
+		{"/dev/null", 0, false},
+	}
+
+	for _, tt := range tests {
+		inode, ok := getSockInode(tt.in)
+		if inode != tt.inode || ok != tt.ok {
+			t.Errorf("getSockInode(%q) = %d %t, want %d %t", tt.in, inode,
+				ok, tt.inode, tt.ok)
+		}
+	}
+}
@@ -28,11 +28,16 @@ func getSysinfo(st *sttsT, vars *varsT) {
 	st.mem.free = int(si.Freeram)
 	st.mem.shared = int(si.Sharedram)
 	st.mem.buffer = int(si.Bufferram)
+	st.mem.swapTotal = int(si.Totalswap)
+	st.mem.swapFree = int(si.Freeswap)
 
 	// add missing info from /proc/meminfo
 	readMeminfo(st, vars)
 	st.mem.used = st.mem.total - st.mem.free - st.mem.buffer - st.mem.cache
 	st.mem.used -= st.mem.huge
+	st.mem.swapUsed = st.mem.swapTotal - st.mem.swapFree
+
+	getZramInfo(st, vars)
 }
 
 func readMeminfo(st *sttsT, vars *varsT) {
@@ -57,6 +62,18 @@ func readMeminfo(st *sttsT, vars *varsT) {
 		case "Hugetlb:":
 			val := parseMeminfoLine(fields)
 			st.mem.huge = val * 1024
+		case "SwapCached:":
+			val := parseMeminfoLine(fields)
+			st.mem.swapCached = val * 1024
+		case "Dirty:":
+			val := parseMeminfoLine(fields)
+			st.mem.dirty = val * 1024
+		case "Writeback:":
+			val := parseMeminfoLine(fields)
+			st.mem.writeback = val * 1024
+		case "AnonHugePages:":
+			val := parseMeminfoLine(fields)
+			st.mem.anonHuge = val * 1024
 		}
 	}
 
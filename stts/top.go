@@ -0,0 +1,195 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/user"
+	"regexp"
+	"sort"
+	"strconv"
+	"time"
+
+	str "strings"
+)
+
+// kernel USER_HZ, fixed at 100 on every architecture that matters here
+const clkTck = 100
+
+const topInterval = time.Second
+
+type topT struct {
+	sort  string
+	user  string
+	match *regexp.Regexp
+	limit int
+}
+
+func printTop(st *sttsT, vars *varsT) {
+	getAllInfo(st, vars)
+	getProcInfo(st, vars)
+	prev := st.ps
+
+	start := time.Now()
+	time.Sleep(topInterval)
+
+	st.ps = nil
+	getAllInfo(st, vars)
+	getProcInfo(st, vars)
+	setProcRates(prev, st.ps, time.Since(start))
+
+	ps := filterProcs(st.ps, vars)
+	sortProcs(ps, vars.top.sort)
+	if vars.top.limit > 0 && len(ps) > vars.top.limit {
+		ps = ps[:vars.top.limit]
+	}
+
+	if vars.format != "" {
+		st.ps = ps
+		printExport(st, vars)
+		return
+	}
+
+	printOneLineOnce(st, vars)
+	sep()
+	printProcTable(st, ps)
+}
+
+// setProcRates computes cpu usage and io rate of every process in cur from
+// the utime, stime and io bytes it had in prev, the earlier sample
+func setProcRates(prev, cur []processT, elapsed time.Duration) {
+	prevProcs := make(map[string]processT)
+	for _, p := range prev {
+		prevProcs[p.pid+":"+strconv.FormatUint(p.stat.starttime, 10)] = p
+	}
+
+	for i := range cur {
+		p := &cur[i]
+		key := p.pid + ":" + strconv.FormatUint(p.stat.starttime, 10)
+		prevP, ok := prevProcs[key]
+		if !ok || elapsed <= 0 {
+			continue
+		}
+
+		delta := tickDelta(prevP.stat.utime+prevP.stat.stime,
+			p.stat.utime+p.stat.stime)
+		p.cpu = delta / clkTck / elapsed.Seconds() * 100
+
+		p.ioRate = counterRate(uint64(prevP.readBytes+prevP.writeBytes),
+			uint64(p.readBytes+p.writeBytes), elapsed.Seconds())
+	}
+}
+
+func filterProcs(ps []processT, vars *varsT) []processT {
+	var res []processT
+
+	for _, p := range ps {
+		if hideProcess(p, vars) {
+			continue
+		}
+
+		if vars.top.user != "" && getUserName(p.uid) != vars.top.user {
+			continue
+		}
+
+		if vars.top.match != nil && !vars.top.match.MatchString(p.args) &&
+			!vars.top.match.MatchString(p.stat.comm) {
+			continue
+		}
+
+		res = append(res, p)
+	}
+
+	return res
+}
+
+func sortProcs(ps []processT, by string) {
+	var less func(a, b processT) bool
+
+	switch by {
+	case "mem":
+		less = func(a, b processT) bool {
+			return a.stat.rss > b.stat.rss
+		}
+	case "io":
+		less = func(a, b processT) bool {
+			return a.ioRate > b.ioRate
+		}
+	case "fds":
+		less = func(a, b processT) bool {
+			return a.fdCount > b.fdCount
+		}
+	default:
+		less = func(a, b processT) bool {
+			return a.cpu > b.cpu
+		}
+	}
+
+	sort.SliceStable(ps, func(i, j int) bool {
+		return less(ps[i], ps[j])
+	})
+}
+
+func printProcTable(st *sttsT, ps []processT) {
+	mb := 1024 * 1024
+	pageSize := int64(os.Getpagesize())
+
+	fmt.Printf("%7s %-10s %s %6s %8s %4s %8s %8s %5s  %s\n",
+		"PID", "USER", "S", "CPU%", "RSS MB", "THR", "AGE",
+		"IO/S", "FDS", "COMMAND")
+
+	for _, p := range ps {
+		rss := float64(p.stat.rss*pageSize) / float64(mb)
+
+		cmd := str.ReplaceAll(p.args, "\n", " ")
+		if cmd == "" {
+			cmd = p.stat.comm
+		}
+
+		fmt.Printf("%7s %-10.10s %c %6.1f %8.1f %4d %8s %8s %5d  %s\n",
+			p.pid, getUserName(p.uid), p.stat.state, p.cpu, rss,
+			p.stat.num_threads, fmtAge(getProcAge(st, p)),
+			fmtBytes(p.ioRate),
+			p.fdCount, cmd)
+	}
+}
+
+func getProcAge(st *sttsT, p processT) time.Duration {
+	started := time.Duration(p.stat.starttime) * time.Second / clkTck
+	if started > st.uptime {
+		return 0
+	}
+	return st.uptime - started
+}
+
+func fmtAge(d time.Duration) string {
+	days := int(d.Hours() / 24)
+	hours := int(d.Hours()) % 24
+	mins := int(d.Minutes()) % 60
+
+	switch {
+	case days > 0:
+		return fmt.Sprintf("%dd%dh", days, hours)
+	case hours > 0:
+		return fmt.Sprintf("%dh%dm", hours, mins)
+	default:
+		return fmt.Sprintf("%dm%ds", mins, int(d.Seconds())%60)
+	}
+}
+
+var userNames = make(map[int]string)
+
+func getUserName(uid int) string {
+	name, ok := userNames[uid]
+	if ok {
+		return name
+	}
+
+	name = strconv.Itoa(uid)
+	u, err := user.LookupId(name)
+	if err == nil {
+		name = u.Username
+	}
+	userNames[uid] = name
+
+	return name
+}
@@ -0,0 +1,130 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"sort"
+
+	str "strings"
+)
+
+type procNodeT struct {
+	p        processT
+	children []*procNodeT
+
+	// totals of the whole subtree including the process itself
+	readBytes  int
+	writeBytes int
+	fdCount    int
+	rss        int64
+}
+
+func printTree(st *sttsT, vars *varsT) {
+	getAllInfo(st, vars)
+	getProcInfo(st, vars)
+
+	roots := getProcTree(st.ps, vars)
+	if len(roots) == 0 {
+		errExit(fmt.Errorf("no matching processes found"))
+	}
+
+	fmt.Printf("%7s %8s %8s %6s  %s\n", "PID", "RSS MB", "IO MB", "FDS",
+		"COMMAND")
+
+	for _, root := range roots {
+		printProcNode(root, "", "")
+	}
+}
+
+// getProcTree links processes to their parents and returns the roots of the
+// requested part of the hierarchy: the -root pid, the topmost processes of
+// the -session or, by default, processes without a known parent
+func getProcTree(ps []processT, vars *varsT) []*procNodeT {
+	// processes that exited during the scan have an empty stat and would
+	// all end up as pid 0, the parent of init
+	nodes := make(map[int]*procNodeT)
+	for _, p := range ps {
+		if p.stat.pid == 0 {
+			continue
+		}
+		nodes[p.stat.pid] = &procNodeT{p: p}
+	}
+
+	for _, node := range nodes {
+		parent, ok := nodes[node.p.stat.ppid]
+		if ok && parent != node {
+			parent.children = append(parent.children, node)
+		}
+	}
+
+	var roots []*procNodeT
+	for _, node := range nodes {
+		parent, hasParent := nodes[node.p.stat.ppid]
+
+		switch {
+		case vars.treeRoot > 0:
+			if node.p.stat.pid == vars.treeRoot {
+				roots = append(roots, node)
+			}
+		case vars.treeSession > 0:
+			if node.p.stat.session == vars.treeSession &&
+				(!hasParent || parent.p.stat.session != vars.treeSession) {
+				roots = append(roots, node)
+			}
+		default:
+			if !hasParent {
+				roots = append(roots, node)
+			}
+		}
+	}
+
+	sortProcNodes(roots)
+	for _, root := range roots {
+		sumProcNode(root)
+	}
+
+	return roots
+}
+
+func sumProcNode(node *procNodeT) {
+	node.readBytes = node.p.readBytes
+	node.writeBytes = node.p.writeBytes
+	node.fdCount = node.p.fdCount
+	node.rss = node.p.stat.rss * int64(os.Getpagesize())
+
+	sortProcNodes(node.children)
+	for _, child := range node.children {
+		sumProcNode(child)
+		node.readBytes += child.readBytes
+		node.writeBytes += child.writeBytes
+		node.fdCount += child.fdCount
+		node.rss += child.rss
+	}
+}
+
+func sortProcNodes(nodes []*procNodeT) {
+	sort.Slice(nodes, func(i, j int) bool {
+		return nodes[i].p.stat.pid < nodes[j].p.stat.pid
+	})
+}
+
+func printProcNode(node *procNodeT, prefix, childPrefix string) {
+	mb := 1024 * 1024
+
+	cmd := str.ReplaceAll(node.p.args, "\n", " ")
+	if cmd == "" {
+		cmd = node.p.stat.comm
+	}
+
+	fmt.Printf("%7s %8.1f %8d %6d  %s%s\n", node.p.pid,
+		float64(node.rss)/float64(mb), (node.readBytes+node.writeBytes)/mb,
+		node.fdCount, prefix, cmd)
+
+	for i, child := range node.children {
+		if i == len(node.children)-1 {
+			printProcNode(child, childPrefix+"└─ ", childPrefix+"   ")
+		} else {
+			printProcNode(child, childPrefix+"├─ ", childPrefix+"│  ")
+		}
+	}
+}
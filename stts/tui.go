@@ -0,0 +1,423 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"os/signal"
+	"regexp"
+	"syscall"
+	"time"
+	"unsafe"
+
+	str "strings"
+)
+
+const tuiInterval = 2 * time.Second
+
+// number of samples kept for temperature sparklines
+const tuiHistLen = 60
+
+var sparkChars = []rune("▁▂▃▄▅▆▇█")
+
+type tuiT struct {
+	paused   bool
+	selected int
+
+	// filter regex being typed after '/'
+	filterInput []rune
+	typing      bool
+
+	tempHist map[string][]int
+	prevPs   []processT
+	prevTime time.Time
+	ps       []processT
+
+	width  int
+	height int
+}
+
+func runTui(st *sttsT, vars *varsT) {
+	oldState, err := setRawTerm(0)
+	errExit(err)
+
+	tui := tuiT{tempHist: make(map[string][]int)}
+
+	// alternate screen buffer and hidden cursor
+	fmt.Print("\x1b[?1049h\x1b[?25l")
+	// errExit doesn't run deferred calls, so it restores through exitHook
+	restore := func() {
+		exitHook = nil
+		fmt.Print("\x1b[?25h\x1b[?1049l")
+		restoreTerm(0, oldState)
+	}
+	exitHook = restore
+	defer restore()
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, syscall.SIGINT, syscall.SIGTERM, syscall.SIGWINCH)
+
+	keys := make(chan byte)
+	go readKeys(keys)
+
+	tick := time.NewTicker(tuiInterval)
+	defer tick.Stop()
+
+	tuiSample(&tui, st, vars)
+	tuiDraw(&tui, st, vars)
+
+	for {
+		select {
+		case sig := <-sigs:
+			if sig != syscall.SIGWINCH {
+				return
+			}
+		case <-tick.C:
+			if !tui.paused {
+				tuiSample(&tui, st, vars)
+			}
+		case key, ok := <-keys:
+			if !ok || !tuiKey(&tui, vars, key) {
+				return
+			}
+			tuiFilter(&tui, st, vars)
+		}
+
+		tuiDraw(&tui, st, vars)
+	}
+}
+
+func tuiSample(tui *tuiT, st *sttsT, vars *varsT) {
+	getAllInfo(st, vars)
+
+	st.ps = nil
+	getProcInfo(st, vars)
+	if tui.prevPs != nil {
+		setProcRates(tui.prevPs, st.ps, time.Since(tui.prevTime))
+	}
+	tui.prevPs = st.ps
+	tui.prevTime = time.Now()
+
+	for _, t := range getTempSegs(st, vars) {
+		hist := append(tui.tempHist[t.group], int(t.temp))
+		if len(hist) > tuiHistLen {
+			hist = hist[len(hist)-tuiHistLen:]
+		}
+		tui.tempHist[t.group] = hist
+	}
+
+	tuiFilter(tui, st, vars)
+}
+
+func tuiFilter(tui *tuiT, st *sttsT, vars *varsT) {
+	tui.ps = filterProcs(st.ps, vars)
+	sortProcs(tui.ps, vars.top.sort)
+
+	if tui.selected >= len(tui.ps) {
+		tui.selected = len(tui.ps) - 1
+	}
+	if tui.selected < 0 {
+		tui.selected = 0
+	}
+}
+
+// tuiKey handles a single key press and returns false when the dashboard
+// should quit
+func tuiKey(tui *tuiT, vars *varsT, key byte) bool {
+	if tui.typing {
+		switch key {
+		case '\r', '\n':
+			tui.typing = false
+			vars.top.match = nil
+			if len(tui.filterInput) > 0 {
+				re, err := regexp.Compile(string(tui.filterInput))
+				if err == nil {
+					vars.top.match = re
+				}
+			}
+		case 0x1b:
+			tui.typing = false
+		case 0x7f, 0x08:
+			if len(tui.filterInput) > 0 {
+				tui.filterInput = tui.filterInput[:len(tui.filterInput)-1]
+			}
+		default:
+			if key >= 0x20 {
+				tui.filterInput = append(tui.filterInput, rune(key))
+			}
+		}
+		return true
+	}
+
+	switch key {
+	case 'q', 0x03:
+		return false
+	case ' ', 'p':
+		tui.paused = !tui.paused
+	case 'c':
+		vars.top.sort = "cpu"
+	case 'm':
+		vars.top.sort = "mem"
+	case 'i':
+		vars.top.sort = "io"
+	case 'd':
+		vars.top.sort = "fds"
+	case 'f':
+		vars.files = !vars.files
+	case 'e':
+		vars.env = !vars.env
+	case 'l':
+		vars.login = !vars.login
+	case 'j':
+		tui.selected++
+	case 'k':
+		tui.selected--
+	case '/':
+		tui.typing = true
+		tui.filterInput = nil
+	}
+
+	return true
+}
+
+func tuiDraw(tui *tuiT, st *sttsT, vars *varsT) {
+	tui.width, tui.height = getTermSize(1)
+
+	var lines []string
+	add := func(format string, a ...interface{}) {
+		lines = append(lines, fmt.Sprintf(format, a...))
+	}
+
+	status := "running"
+	if tui.paused {
+		status = "paused"
+	}
+	add("stts  up %s  %s  sort:%s  [c]pu [m]em [i]o [d]fds [/]filter "+
+		"[f]iles [e]nv [l]ogin [j/k]select [p]ause [q]uit",
+		fmtAge(st.uptime), status, vars.top.sort)
+	add("")
+
+	add("load %.2f %.2f %.2f   procs %d", st.loads[0], st.loads[1],
+		st.loads[2], st.procs)
+	if vars.has.cpuUsage {
+		cpu := fmt.Sprintf("cpu %5.1f%%  us %.1f sy %.1f wa %.1f st %.1f",
+			st.cpu.total.busy, st.cpu.total.user, st.cpu.total.system,
+			st.cpu.total.iowait, st.cpu.total.steal)
+		if len(st.cpu.freqs) > 0 {
+			cpu += fmt.Sprintf("  %dMHz", avgCpuFreq(st.cpu.freqs))
+		}
+		add("%s", cpu)
+	}
+	add("%s of %.1fG   avail %.1fG   swap %.1fG of %.1fG", fmtMem(st),
+		float64(st.mem.total)/(1024*1024*1024),
+		float64(st.mem.avail)/(1024*1024*1024),
+		float64(st.mem.swapUsed)/(1024*1024*1024),
+		float64(st.mem.swapTotal)/(1024*1024*1024))
+	if vars.has.psi {
+		p := st.pressure
+		add("psi some cpu %.1f mem %.1f io %.1f   oom %d", p.cpu.some[0],
+			p.mem.some[0], p.io.some[0], p.oomKills)
+	}
+	for _, d := range st.disks {
+		add("%s", fmtDiskMount(d))
+	}
+	for _, n := range st.net {
+		if netShown(n, vars) {
+			add("%s", fmtNet(n))
+		}
+	}
+	add("")
+
+	for _, t := range getTempSegs(st, vars) {
+		add("%-24s %s", t.text, sparkline(tui.tempHist[t.group]))
+	}
+
+	for _, w := range st.wifis {
+		add("%s", fmtWifi(w))
+	}
+	if vars.show.vpn {
+		add("%s", fmtVpn(st))
+	}
+	if vars.has.bat {
+		add("%s", fmtBat(st))
+	}
+	add("")
+
+	if tui.typing {
+		add("filter: %s_", string(tui.filterInput))
+	} else if vars.top.match != nil {
+		add("filter: %s", vars.top.match.String())
+	} else {
+		add("")
+	}
+
+	add("%7s %-10s %s %6s %8s %4s %8s %5s  %s", "PID", "USER", "S",
+		"CPU%", "RSS MB", "THR", "AGE", "FDS", "COMMAND")
+
+	var detail []string
+	if tui.selected < len(tui.ps) {
+		detail = getProcDetail(tui.ps[tui.selected], vars)
+	}
+
+	// files and env of the selected process go to the bottom, the process
+	// list gets the rest of the screen and scrolls to keep the selection
+	rows := tui.height - len(lines) - len(detail)
+	if rows < 1 {
+		rows = 1
+	}
+	start := 0
+	if tui.selected >= rows {
+		start = tui.selected - rows + 1
+	}
+
+	pageSize := int64(os.Getpagesize())
+	for i := start; i < len(tui.ps) && i < start+rows; i++ {
+		p := tui.ps[i]
+
+		cmd := str.ReplaceAll(p.args, "\n", " ")
+		if cmd == "" {
+			cmd = p.stat.comm
+		}
+
+		mark := " "
+		if i == tui.selected {
+			mark = ">"
+		}
+
+		rss := float64(p.stat.rss*pageSize) / (1024 * 1024)
+		add("%s%6s %-10.10s %c %6.1f %8.1f %4d %8s %5d  %s", mark,
+			p.pid, getUserName(p.uid), p.stat.state, p.cpu, rss,
+			p.stat.num_threads, fmtAge(getProcAge(st, p)), p.fdCount,
+			cmd)
+	}
+	lines = append(lines, detail...)
+
+	var sb str.Builder
+	sb.WriteString("\x1b[H\x1b[2J")
+	for i, line := range lines {
+		if i >= tui.height {
+			break
+		}
+		sb.WriteString(truncRunes(line, tui.width))
+		if i < len(lines)-1 {
+			sb.WriteString("\r\n")
+		}
+	}
+	fmt.Print(sb.String())
+}
+
+func getProcDetail(p processT, vars *varsT) []string {
+	var detail []string
+
+	if vars.files {
+		detail = append(detail, "files of "+p.pid+":")
+		for _, file := range p.files {
+			detail = append(detail, "    "+file)
+		}
+	}
+
+	if vars.env {
+		detail = append(detail, "env vars of "+p.pid+":")
+		for _, env := range p.env {
+			detail = append(detail, "    "+env)
+		}
+	}
+
+	return detail
+}
+
+func sparkline(vals []int) string {
+	if len(vals) == 0 {
+		return ""
+	}
+
+	min, max := vals[0], vals[0]
+	for _, v := range vals {
+		if v < min {
+			min = v
+		}
+		if v > max {
+			max = v
+		}
+	}
+
+	var sb str.Builder
+	for _, v := range vals {
+		i := 0
+		if max > min {
+			i = (v - min) * (len(sparkChars) - 1) / (max - min)
+		}
+		sb.WriteRune(sparkChars[i])
+	}
+	fmt.Fprintf(&sb, " %d-%d", min, max)
+
+	return sb.String()
+}
+
+func truncRunes(s string, width int) string {
+	if width <= 0 {
+		return s
+	}
+
+	runes := []rune(s)
+	if len(runes) > width {
+		return string(runes[:width])
+	}
+	return s
+}
+
+func readKeys(keys chan<- byte) {
+	buf := make([]byte, 1)
+	for {
+		n, err := os.Stdin.Read(buf)
+		if err != nil {
+			close(keys)
+			return
+		}
+		if n == 1 {
+			keys <- buf[0]
+		}
+	}
+}
+
+func setRawTerm(fd int) (syscall.Termios, error) {
+	var old syscall.Termios
+	err := ioctl(fd, syscall.TCGETS, unsafe.Pointer(&old))
+	if err != nil {
+		return old, fmt.Errorf("stdin is not a terminal: %w", err)
+	}
+
+	raw := old
+	raw.Lflag &^= syscall.ICANON | syscall.ECHO | syscall.ISIG
+	raw.Iflag &^= syscall.ICRNL | syscall.IXON
+	raw.Cc[syscall.VMIN] = 1
+	raw.Cc[syscall.VTIME] = 0
+
+	err = ioctl(fd, syscall.TCSETS, unsafe.Pointer(&raw))
+	return old, err
+}
+
+func restoreTerm(fd int, state syscall.Termios) {
+	ioctl(fd, syscall.TCSETS, unsafe.Pointer(&state))
+}
+
+func getTermSize(fd int) (int, int) {
+	var ws struct {
+		row, col, xpixel, ypixel uint16
+	}
+
+	err := ioctl(fd, syscall.TIOCGWINSZ, unsafe.Pointer(&ws))
+	if err != nil || ws.col == 0 {
+		return 80, 24
+	}
+
+	return int(ws.col), int(ws.row)
+}
+
+func ioctl(fd int, req uint, arg unsafe.Pointer) error {
+	_, _, errno := syscall.Syscall(syscall.SYS_IOCTL, uintptr(fd),
+		uintptr(req), uintptr(arg))
+	if errno != 0 {
+		return errno
+	}
+	return nil
+}
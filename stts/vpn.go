@@ -0,0 +1,214 @@
+package main
+
+import (
+	"bufio"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"strconv"
+	"time"
+
+	fp "path/filepath"
+	str "strings"
+)
+
+// link type of interfaces without a link layer, e.g. tun and wireguard
+const arphrdNone = "65534"
+
+type vpnT struct {
+	up bool
+
+	// set only for the checks that are configured
+	pidAlive   bool
+	routeFound bool
+
+	// tunnel interface, vpn_iface or the tun or wireguard device found
+	iface   string
+	rxBytes uint64
+	txBytes uint64
+	rxRate  float64
+	txRate  float64
+}
+
+func detectVpn(vars *varsT) {
+	if vars.vpnRoute != "" {
+		if net.ParseIP(vars.vpnRoute).To4() == nil {
+			errExit(fmt.Errorf("incorrect vpn_route ipv4 address: %s",
+				vars.vpnRoute))
+		}
+
+		fd, err := os.Open("/proc/net/route")
+		errExit(err)
+		vars.vpnRouteFd = fd
+	}
+
+	var vpn vpnT
+	setVpnState(&vpn, vars)
+	if vpn.iface != "" {
+		vars.vpnRxPrev, vars.vpnTxPrev = readVpnCounters(vpn.iface)
+		vars.vpnIfacePrev = vpn.iface
+	}
+	vars.vpnTime = time.Now()
+}
+
+// getVpnInfo checks the vpn process, route and interface; the tunnel
+// interface can come and go with the vpn, so its counters are opened on
+// every sample
+func getVpnInfo(st *sttsT, vars *varsT) {
+	if !vars.show.vpn {
+		return
+	}
+
+	vpn := &st.vpn
+	setVpnState(vpn, vars)
+
+	vpn.rxBytes, vpn.txBytes, vpn.rxRate, vpn.txRate = 0, 0, 0, 0
+	if vpn.iface == "" {
+		vars.vpnIfacePrev = ""
+		vars.vpnTime = time.Now()
+		return
+	}
+
+	if vars.vpnIfacePrev == vpn.iface {
+		waitSample(vars.vpnTime, vars)
+	}
+
+	vpn.rxBytes, vpn.txBytes = readVpnCounters(vpn.iface)
+	elapsed := time.Since(vars.vpnTime).Seconds()
+	vars.vpnTime = time.Now()
+
+	if vars.vpnIfacePrev == vpn.iface && elapsed > 0 {
+		vpn.rxRate = counterRate(vars.vpnRxPrev, vpn.rxBytes, elapsed)
+		vpn.txRate = counterRate(vars.vpnTxPrev, vpn.txBytes, elapsed)
+	}
+	vars.vpnIfacePrev = vpn.iface
+	vars.vpnRxPrev = vpn.rxBytes
+	vars.vpnTxPrev = vpn.txBytes
+}
+
+// setVpnState sets whether the vpn is up and its tunnel interface
+func setVpnState(vpn *vpnT, vars *varsT) {
+	vpn.up = true
+
+	if vars.vpnPidFile != "" {
+		vpn.pidAlive = isPidFileAlive(vars.vpnPidFile)
+		vpn.up = vpn.up && vpn.pidAlive
+	}
+
+	vpn.iface = ""
+	if vars.vpnRouteFd != nil {
+		routeIface := findRouteIface(vars)
+		vpn.routeFound = routeIface != ""
+		vpn.up = vpn.up && vpn.routeFound
+
+		// a route to the vpn server goes through the lan interface
+		if isTunIface(routeIface) {
+			vpn.iface = routeIface
+		}
+	}
+
+	switch {
+	case vars.vpnIface != "":
+		if fileExists(fp.Join("/sys/class/net", vars.vpnIface)) {
+			vpn.iface = vars.vpnIface
+		}
+		vpn.up = vpn.up && vpn.iface != ""
+	case vpn.iface == "" && vpn.up:
+		vpn.iface = findTunIface()
+	}
+}
+
+func readVpnCounters(iface string) (uint64, uint64) {
+	dir := fp.Join("/sys/class/net", iface, "statistics")
+	return uint64(readSysFloat(fp.Join(dir, "rx_bytes"))),
+		uint64(readSysFloat(fp.Join(dir, "tx_bytes")))
+}
+
+func isPidFileAlive(pidFile string) bool {
+	pid, err := strconv.Atoi(readSysString(pidFile))
+	if err != nil || pid <= 0 {
+		return false
+	}
+
+	return fileExists(fp.Join("/proc", strconv.Itoa(pid)))
+}
+
+// findRouteIface returns the interface of a route in /proc/net/route whose
+// gateway or destination is vpn_route, empty when there is none
+func findRouteIface(vars *varsT) string {
+	bin, err := io.ReadAll(vars.vpnRouteFd)
+
+	// skip for benchmarking as this poses a large i/o bottleneck
+	if !vars.bench {
+		vars.vpnRouteFd.Seek(0, 0)
+	}
+
+	if err != nil {
+		return ""
+	}
+
+	// addresses are hex in host byte order, assumed to be little-endian
+	ip := net.ParseIP(vars.vpnRoute).To4()
+	routeHex := fmt.Sprintf("%08X", binary.LittleEndian.Uint32(ip))
+
+	input := bufio.NewScanner(str.NewReader(string(bin)))
+	input.Scan()
+	for input.Scan() {
+		fields := str.Fields(input.Text())
+		if len(fields) < 3 {
+			continue
+		}
+
+		dest, gateway := fields[1], fields[2]
+		if str.EqualFold(dest, routeHex) ||
+			str.EqualFold(gateway, routeHex) {
+			return fields[0]
+		}
+	}
+
+	return ""
+}
+
+// isTunIface tells whether an interface is a tun or tap device, which have
+// tun_flags, or has no link layer like wireguard
+func isTunIface(name string) bool {
+	if name == "" {
+		return false
+	}
+
+	dir := fp.Join("/sys/class/net", name)
+	return fileExists(fp.Join(dir, "tun_flags")) ||
+		readSysString(fp.Join(dir, "type")) == arphrdNone
+}
+
+// findTunIface returns the first tunnel interface by name, empty when there
+// is none
+func findTunIface() string {
+	entries, err := os.ReadDir("/sys/class/net")
+	if err != nil {
+		return ""
+	}
+
+	for _, entry := range entries {
+		if isTunIface(entry.Name()) {
+			return entry.Name()
+		}
+	}
+
+	return ""
+}
+
+func fmtVpn(st *sttsT) string {
+	if !st.vpn.up {
+		return "vpn down"
+	}
+
+	if st.vpn.iface == "" {
+		return "vpn up"
+	}
+
+	return fmt.Sprintf("vpn ↓%s ↑%s", fmtBytes(st.vpn.rxRate),
+		fmtBytes(st.vpn.txRate))
+}
@@ -0,0 +1,293 @@
+package main
+
+import (
+	"fmt"
+	"net"
+	"sort"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"github.com/mdlayher/netlink/nlenc"
+	"github.com/mdlayher/wifi"
+	"golang.org/x/sys/unix"
+)
+
+type wifiT struct {
+	iface string
+	dev   *wifi.Interface
+	bss   *wifi.BSS
+	info  *wifi.StationInfo
+}
+
+type wifiScanT struct {
+	bssid  string
+	ssid   string
+	freq   int
+	signal float64
+}
+
+// detectWlan picks the station interfaces listed by wifi_iface, or all of
+// them when there is none
+func detectWlan(vars *varsT) {
+	client, err := wifi.New()
+	if err != nil {
+		return
+	}
+
+	ifaces, err := client.Interfaces()
+	if err != nil {
+		client.Close()
+		return
+	}
+
+	for _, iface := range ifaces {
+		// p2p devices are listed without a netdev name
+		if iface.Name == "" || iface.Type != wifi.InterfaceTypeStation {
+			continue
+		}
+		if len(vars.wifiIfaceNames) > 0 &&
+			!elInSlice(vars.wifiIfaceNames, iface.Name) {
+			continue
+		}
+		vars.wifiIfaces = append(vars.wifiIfaces, iface)
+	}
+
+	if len(vars.wifiIfaces) == 0 {
+		client.Close()
+		return
+	}
+
+	sort.Slice(vars.wifiIfaces, func(i, j int) bool {
+		return vars.wifiIfaces[i].Name < vars.wifiIfaces[j].Name
+	})
+
+	vars.wifiClient = client
+	vars.nl80211 = openNl80211()
+}
+
+func getWifiInfo(st *sttsT, vars *varsT) {
+	if vars.wifiClient == nil {
+		return
+	}
+
+	st.wifis = st.wifis[:0]
+
+	for _, iface := range vars.wifiIfaces {
+		w := wifiT{iface: iface.Name, dev: iface}
+
+		// both fail when the interface isn't connected
+		w.bss, _ = vars.wifiClient.BSS(iface)
+		infos, _ := vars.wifiClient.StationInfo(iface)
+		if len(infos) > 0 {
+			w.info = infos[0]
+		}
+
+		st.wifis = append(st.wifis, w)
+	}
+}
+
+func (w wifiT) connected() bool {
+	return w.bss != nil && w.bss.SSID != ""
+}
+
+// wifiBand returns the band and channel number of a frequency in MHz
+func wifiBand(freq int) (string, int) {
+	switch {
+	case freq == 2484:
+		return "2.4GHz", 14
+	case freq >= 2412 && freq < 2484:
+		return "2.4GHz", (freq - 2407) / 5
+	case freq >= 5955 && freq <= 7115:
+		return "6GHz", (freq - 5950) / 5
+	case freq >= 5000 && freq < 5955:
+		return "5GHz", (freq - 5000) / 5
+	case freq >= 58320 && freq <= 70200:
+		return "60GHz", (freq - 56160) / 2160
+	}
+	return "", 0
+}
+
+// nl80211 is opened next to the wifi client for what it doesn't provide,
+// the channel width and the networks of the last scan
+type nl80211T struct {
+	conn    *genetlink.Conn
+	family  uint16
+	version uint8
+}
+
+// channel widths in MHz by nl80211_chan_width
+var chanWidths = map[uint32]int{
+	unix.NL80211_CHAN_WIDTH_20_NOHT: 20,
+	unix.NL80211_CHAN_WIDTH_20:      20,
+	unix.NL80211_CHAN_WIDTH_40:      40,
+	unix.NL80211_CHAN_WIDTH_80:      80,
+	unix.NL80211_CHAN_WIDTH_80P80:   160,
+	unix.NL80211_CHAN_WIDTH_160:     160,
+	unix.NL80211_CHAN_WIDTH_5:       5,
+	unix.NL80211_CHAN_WIDTH_10:      10,
+}
+
+// ieSSID is the id of the ssid information element
+const ieSSID = 0
+
+func openNl80211() *nl80211T {
+	conn, err := genetlink.Dial(nil)
+	if err != nil {
+		return nil
+	}
+
+	family, err := conn.GetFamily(unix.NL80211_GENL_NAME)
+	if err != nil {
+		conn.Close()
+		return nil
+	}
+
+	return &nl80211T{conn: conn, family: family.ID, version: family.Version}
+}
+
+func (nl *nl80211T) get(cmd uint8, flags netlink.HeaderFlags,
+	iface *wifi.Interface) ([]genetlink.Message, error) {
+
+	ae := netlink.NewAttributeEncoder()
+	ae.Uint32(unix.NL80211_ATTR_IFINDEX, uint32(iface.Index))
+	data, err := ae.Encode()
+	if err != nil {
+		return nil, err
+	}
+
+	msg := genetlink.Message{
+		Header: genetlink.Header{Command: cmd, Version: nl.version},
+		Data:   data,
+	}
+	return nl.conn.Execute(msg, nl.family, netlink.Request|flags)
+}
+
+// getWifiWidth returns the channel width in MHz, 0 when unknown
+func getWifiWidth(iface *wifi.Interface, vars *varsT) int {
+	if vars.nl80211 == nil {
+		return 0
+	}
+
+	msgs, err := vars.nl80211.get(unix.NL80211_CMD_GET_INTERFACE, 0, iface)
+	if err != nil {
+		return 0
+	}
+
+	for _, msg := range msgs {
+		ad, err := netlink.NewAttributeDecoder(msg.Data)
+		if err != nil {
+			continue
+		}
+		for ad.Next() {
+			if ad.Type() == unix.NL80211_ATTR_CHANNEL_WIDTH {
+				return chanWidths[ad.Uint32()]
+			}
+		}
+	}
+
+	return 0
+}
+
+// getWifiScan lists the networks found by the last scan of an interface,
+// strongest first; the scan dump doesn't trigger a new scan and so doesn't
+// need root
+func getWifiScan(iface *wifi.Interface, vars *varsT) []wifiScanT {
+	if vars.nl80211 == nil {
+		return nil
+	}
+
+	msgs, err := vars.nl80211.get(unix.NL80211_CMD_GET_SCAN, netlink.Dump,
+		iface)
+	if err != nil {
+		return nil
+	}
+
+	return parseWifiScan(msgs)
+}
+
+func parseWifiScan(msgs []genetlink.Message) []wifiScanT {
+	var scan []wifiScanT
+
+	for _, msg := range msgs {
+		attrs, err := netlink.UnmarshalAttributes(msg.Data)
+		if err != nil {
+			continue
+		}
+
+		for _, attr := range attrs {
+			// the kernel doesn't set the nested flag on it, but may
+			if attr.Type&^netlink.Nested != unix.NL80211_ATTR_BSS {
+				continue
+			}
+
+			bssAttrs, err := netlink.UnmarshalAttributes(attr.Data)
+			if err != nil {
+				continue
+			}
+			scan = append(scan, parseWifiBss(bssAttrs))
+		}
+	}
+
+	sort.SliceStable(scan, func(i, j int) bool {
+		return scan[i].signal > scan[j].signal
+	})
+
+	return scan
+}
+
+func parseWifiBss(attrs []netlink.Attribute) wifiScanT {
+	var bss wifiScanT
+
+	for _, attr := range attrs {
+		switch attr.Type {
+		case unix.NL80211_BSS_BSSID:
+			bss.bssid = net.HardwareAddr(attr.Data).String()
+		case unix.NL80211_BSS_FREQUENCY:
+			if len(attr.Data) == 4 {
+				bss.freq = int(nlenc.Uint32(attr.Data))
+			}
+		case unix.NL80211_BSS_SIGNAL_MBM:
+			// in mBm, hundredths of a dBm
+			if len(attr.Data) == 4 {
+				bss.signal = float64(nlenc.Int32(attr.Data)) / 100
+			}
+		case unix.NL80211_BSS_INFORMATION_ELEMENTS:
+			bss.ssid = parseIeSsid(attr.Data)
+		}
+	}
+
+	return bss
+}
+
+// parseIeSsid returns the ssid of information elements, which are an id
+// and a length byte followed by the data
+func parseIeSsid(ies []byte) string {
+	for len(ies) >= 2 {
+		id, size := ies[0], int(ies[1])
+		if len(ies) < 2+size {
+			break
+		}
+		if id == ieSSID {
+			return string(ies[2 : 2+size])
+		}
+		ies = ies[2+size:]
+	}
+	return ""
+}
+
+func fmtWifi(w wifiT) string {
+	if !w.connected() {
+		return w.iface + " no conn"
+	}
+
+	wifi := w.iface + " " + w.bss.SSID
+	if w.info != nil {
+		wifi += fmt.Sprintf(" %ddBm", w.info.Signal)
+	}
+	return wifi
+}
+
+// fmtBitrate formats a bitrate in bits per second as Mbit/s
+func fmtBitrate(bps int) string {
+	return fmt.Sprintf("%.1f Mbit/s", float64(bps)/1e6)
+}
@@ -0,0 +1,107 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/mdlayher/genetlink"
+	"github.com/mdlayher/netlink"
+	"golang.org/x/sys/unix"
+)
+
+func TestWifiBand(t *testing.T) {
+	tests := []struct {
+		freq    int
+		band    string
+		channel int
+	}{
+		{2412, "2.4GHz", 1},
+		{2437, "2.4GHz", 6},
+		{2472, "2.4GHz", 13},
+		{2484, "2.4GHz", 14},
+		{5180, "5GHz", 36},
+		{5500, "5GHz", 100},
+		{5825, "5GHz", 165},
+		{5955, "6GHz", 1},
+		{6115, "6GHz", 33},
+		{7115, "6GHz", 233},
+		{58320, "60GHz", 1},
+		{60480, "60GHz", 2},
+		{0, "", 0},
+		{2400, "", 0},
+		{900, "", 0},
+	}
+
+	for _, tt := range tests {
+		band, channel := wifiBand(tt.freq)
+		if band != tt.band || channel != tt.channel {
+			t.Errorf("wifiBand(%d) = %s %d, want %s %d", tt.freq, band,
+				channel, tt.band, tt.channel)
+		}
+	}
+}
+
+func TestParseIeSsid(t *testing.T) {
+	tests := []struct {
+		ies  []byte
+		want string
+	}{
+		{[]byte{0, 4, 'h', 'o', 'm', 'e'}, "home"},
+		{[]byte{1, 2, 0x82, 0x84, 0, 3, 'a', 'b', 'c'}, "abc"},
+		{[]byte{0, 0}, ""},
+		{[]byte{0, 8, 'c', 'u', 't'}, ""},
+		{[]byte{1, 1}, ""},
+		{nil, ""},
+	}
+
+	for _, tt := range tests {
+		if got := parseIeSsid(tt.ies); got != tt.want {
+			t.Errorf("parseIeSsid(%v) = %q, want %q", tt.ies, got, tt.want)
+		}
+	}
+}
+
+func TestParseWifiScan(t *testing.T) {
+	bss := func(mac []byte, freq uint32, mbm int32, ssid string) []byte {
+		ae := netlink.NewAttributeEncoder()
+		ae.Uint32(unix.NL80211_ATTR_IFINDEX, 3)
+		ae.Nested(unix.NL80211_ATTR_BSS, func(
+			bae *netlink.AttributeEncoder) error {
+			bae.Bytes(unix.NL80211_BSS_BSSID, mac)
+			bae.Uint32(unix.NL80211_BSS_FREQUENCY, freq)
+			bae.Int32(unix.NL80211_BSS_SIGNAL_MBM, mbm)
+			bae.Bytes(unix.NL80211_BSS_INFORMATION_ELEMENTS,
+				append([]byte{0, byte(len(ssid))}, ssid...))
+			return nil
+		})
+		data, err := ae.Encode()
+		if err != nil {
+			t.Fatal(err)
+		}
+		return data
+	}
+
+	msgs := []genetlink.Message{
+		{Data: bss([]byte{0, 0x11, 0x22, 0x33, 0x44, 0x55}, 2437, -7200,
+			"far")},
+		{Data: bss([]byte{0xaa, 0xbb, 0xcc, 0xdd, 0xee, 0xff}, 5180, -4150,
+			"near")},
+		{Data: []byte{1, 2, 3}},
+	}
+
+	want := []wifiScanT{
+		{bssid: "aa:bb:cc:dd:ee:ff", ssid: "near", freq: 5180,
+			signal: -41.5},
+		{bssid: "00:11:22:33:44:55", ssid: "far", freq: 2437, signal: -72},
+	}
+
+	got := parseWifiScan(msgs)
+	if len(got) != len(want) {
+		t.Fatalf("parseWifiScan() = %+v, want %+v", got, want)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("parseWifiScan()[%d] = %+v, want %+v", i, got[i],
+				want[i])
+		}
+	}
+}